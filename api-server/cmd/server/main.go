@@ -1,55 +1,146 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/truegul/api-server/internal/callbackauth"
 	"github.com/truegul/api-server/internal/config"
 	"github.com/truegul/api-server/internal/database"
+	"github.com/truegul/api-server/internal/events"
 	"github.com/truegul/api-server/internal/handler"
+	"github.com/truegul/api-server/internal/loginguard"
 	"github.com/truegul/api-server/internal/middleware"
 	"github.com/truegul/api-server/internal/mq"
+	"github.com/truegul/api-server/internal/ratelimit"
 	"github.com/truegul/api-server/internal/repository"
 	"github.com/truegul/api-server/internal/service"
+	"github.com/truegul/api-server/internal/worker"
 )
 
 func main() {
 	cfg := config.Load()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	db, err := database.Connect(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	publisher, err := mq.NewRedisPublisher(cfg.RedisURL, cfg.StreamName)
+	// Without Redis, the features built on it fall back to per-process state.
+	var redisClient *redis.Client
+	if cfg.RedisURL != "" {
+		redisClient, err = database.ConnectRedis(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		defer redisClient.Close()
+	} else {
+		log.Println("REDIS_URL is not set; rate limits, login throttling, callback nonces and analysis events are kept per process")
+	}
+
+	publisher, err := mq.Open(cfg.MQBackend, mq.Options{
+		RedisURL:          cfg.RedisURL,
+		DB:                db,
+		StreamName:        cfg.StreamName,
+		ControlStreamName: cfg.ControlStream,
+		StreamMaxLen:      cfg.StreamMaxLen,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		log.Fatalf("Failed to open %s message queue: %v", cfg.MQBackend, err)
 	}
 	defer publisher.Close()
 
+	// Backends differ in what they support beyond publishing.
+	inspector, _ := publisher.(mq.Inspector)
+	deadLetters, _ := publisher.(mq.DeadLetterQueue)
+
+	var broker events.Broker = events.NewLocalBroker()
+	if redisClient != nil {
+		broker = events.NewRedisBroker(redisClient)
+	}
+	limiter := ratelimit.NewLimiter(redisClient)
+	callbackVerifier := callbackauth.NewVerifier(redisClient, []string{cfg.MLCallbackSecret, cfg.MLCallbackSecretPrevious}, cfg.CallbackMaxSkew)
+	authRateLimit := ratelimit.Limit{PerMinute: cfg.AuthRateLimitPerMinute, Burst: cfg.AuthRateLimitBurst}
+	submitRateLimit := ratelimit.Limit{PerMinute: cfg.SubmitRateLimitPerMinute, Burst: cfg.SubmitRateLimitBurst}
+
 	userRepo := repository.NewUserRepository(db)
 	writingRepo := repository.NewWritingRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
-
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret, cfg.JWTExpiry)
-	writingService := service.NewWritingService(writingRepo)
-	analysisService := service.NewAnalysisService(analysisRepo, writingRepo, userRepo, publisher, cfg)
+	outboxRepo := repository.NewOutboxRepository(db)
+	planRepo := repository.NewPlanRepository(db)
+	lockoutRepo := repository.NewLoginLockoutRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	transactor := repository.NewTransactor(db)
+	locker := repository.NewAdvisoryLocker(db)
+
+	loginGuard := loginguard.NewGuard(redisClient,
+		loginguard.Policy{
+			Window:       cfg.LoginFailureWindow,
+			FreeAttempts: 3,
+			BaseDelay:    time.Second,
+			MaxDelay:     30 * time.Second,
+		},
+		loginguard.Policy{
+			Window:          cfg.LoginFailureWindow,
+			FreeAttempts:    10,
+			BaseDelay:       time.Second,
+			MaxDelay:        30 * time.Second,
+			Threshold:       cfg.LoginIPMaxFailures,
+			LockoutDuration: cfg.LoginLockout,
+		},
+	)
+	authService := service.NewAuthService(transactor, userRepo, sessionRepo, lockoutRepo, loginGuard, cfg.JWTSecret, cfg.JWTExpiry, cfg.RefreshTokenTTL)
+	writingService := service.NewWritingService(writingRepo, planRepo)
+	analysisService := service.NewAnalysisService(transactor, analysisRepo, writingRepo, userRepo, planRepo, publisher, broker, cfg)
+	planService := service.NewPlanService(planRepo, userRepo)
+	deadLetterService := service.NewDeadLetterService(deadLetters, analysisRepo)
 
 	authHandler := handler.NewAuthHandler(authService, cfg.Environment)
 	writingHandler := handler.NewWritingHandler(writingService)
 	analysisHandler := handler.NewAnalysisHandler(analysisService, cfg)
-	healthHandler := handler.NewHealthHandler(db, publisher.Client())
+	adminHandler := handler.NewAdminHandler(analysisService, planService, deadLetterService)
+	healthHandler := handler.NewHealthHandler(db, redisClient, publisher)
+
+	// Backends that park delayed tasks need a sweep; those that can report
+	// claimed tasks let the reaper requeue or dead-letter them.
+	if scheduler, ok := publisher.(mq.Scheduler); ok {
+		go worker.Run(ctx, worker.NewRetryScheduler(scheduler), cfg.RetrySchedulerInterval)
+	}
+	// The channel backend has no out-of-process consumer of its own.
+	if channel, ok := publisher.(*mq.ChannelPublisher); ok {
+		go worker.Run(ctx, worker.NewTaskForwarder(channel, cfg.MLServerURL, cfg.MLCallbackSecret), cfg.ChannelForwardInterval)
+	}
+	go worker.Run(ctx, worker.NewOutboxRelay(outboxRepo, publisher, analysisService, cfg.OutboxMaxAge), cfg.OutboxRelayInterval)
+	reaper := worker.NewReaper(analysisService, analysisRepo, inspector, deadLetters, cfg.StreamGroup, cfg.AnalysisTimeout, cfg.TaskMaxDeliveries)
+	go worker.Run(ctx, worker.Exclusive(reaper, locker, worker.ReaperLockKey), cfg.ReaperInterval)
+	go worker.Run(ctx, worker.NewTrashPurger(writingRepo, cfg.TrashRetention), cfg.TrashPurgeInterval)
+	if redisClient != nil {
+		resultReader := mq.NewResultReader(redisClient, cfg.ResultsStream, cfg.ResultsGroup, cfg.ResultsConsumer, cfg.ResultsClaimIdle)
+		go worker.Run(ctx, worker.NewResultConsumer(analysisService, resultReader, cfg.ResultsMaxDeliveries), cfg.ResultsConsumerInterval)
+	}
 
 	r := gin.Default()
 
+	// Rate limits and login lockouts key on c.ClientIP(), so forwarded
+	// headers are only honoured from the proxies we run.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
+
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-CSRF-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", handler.RateLimitLimitHeader, handler.RateLimitRemainingHeader, handler.RateLimitResetHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -67,15 +158,19 @@ func main() {
 		})
 
 		auth := v1.Group("/auth")
+		authLimit := middleware.RateLimitMiddleware(limiter, "auth", authRateLimit, middleware.ByClientIP)
 		{
-			auth.POST("/signup", authHandler.Signup)
-			auth.POST("/login", authHandler.Login)
+			auth.POST("/signup", authLimit, authHandler.Signup)
+			auth.POST("/login", authLimit, authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
 			auth.POST("/logout", authHandler.Logout)
 		}
 
-		internal := v1.Group("/internal")
-		{
-			internal.POST("/callback", analysisHandler.Callback)
+		if cfg.CallbackHTTPEnabled {
+			internal := v1.Group("/internal")
+			{
+				internal.POST("/callback", middleware.CallbackAuthMiddleware(callbackVerifier), analysisHandler.Callback)
+			}
 		}
 
 		protected := v1.Group("")
@@ -83,16 +178,42 @@ func main() {
 		protected.Use(middleware.CSRFMiddleware())
 		{
 			protected.GET("/auth/me", authHandler.Me)
+			protected.PUT("/auth/me/timezone", authHandler.UpdateTimezone)
+			protected.GET("/me/quota", analysisHandler.Quota)
+			protected.GET("/me/sessions", authHandler.ListSessions)
+			protected.DELETE("/me/sessions", authHandler.RevokeAllSessions)
+			protected.DELETE("/me/sessions/:id", authHandler.RevokeSession)
 
 			writings := protected.Group("/writings")
 			{
 				writings.POST("", writingHandler.Create)
 				writings.GET("", writingHandler.List)
+				writings.GET("/trash", writingHandler.ListTrash)
 				writings.GET("/:id", writingHandler.GetByID)
 				writings.PUT("/:id", writingHandler.Update)
 				writings.DELETE("/:id", writingHandler.Delete)
-				writings.POST("/:id/submit", analysisHandler.Submit)
+				writings.POST("/:id/restore", writingHandler.Restore)
+				writings.GET("/:id/revisions", writingHandler.ListRevisions)
+				writings.POST("/:id/submit", middleware.RateLimitMiddleware(limiter, "submit", submitRateLimit, middleware.ByUser), analysisHandler.Submit)
 				writings.GET("/:id/analysis", analysisHandler.GetAnalysis)
+				writings.GET("/:id/analysis/events", analysisHandler.Events)
+				writings.POST("/:id/analysis/cancel", analysisHandler.Cancel)
+				writings.GET("/:id/analyses", analysisHandler.ListAnalyses)
+				writings.GET("/:id/analyses/diff", analysisHandler.DiffAnalyses)
+			}
+
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(authService))
+			{
+				admin.GET("/metrics", gin.WrapH(expvar.Handler()))
+				admin.GET("/analysis-logs", adminHandler.ListAnalysisLogs)
+				admin.GET("/plans", adminHandler.ListPlans)
+				admin.PUT("/users/:id/plan", adminHandler.AssignPlan)
+				admin.GET("/dead-letters", adminHandler.ListDeadLetters)
+				admin.DELETE("/dead-letters", adminHandler.PurgeDeadLetters)
+				admin.GET("/dead-letters/:id", adminHandler.GetDeadLetter)
+				admin.DELETE("/dead-letters/:id", adminHandler.PurgeDeadLetter)
+				admin.POST("/dead-letters/:id/replay", adminHandler.ReplayDeadLetter)
 			}
 		}
 	}
@@ -0,0 +1,144 @@
+package callbackauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+)
+
+const (
+	TimestampHeader = "X-Callback-Timestamp"
+	NonceHeader     = "X-Callback-Nonce"
+	SignatureHeader = "X-Callback-Signature"
+
+	signaturePrefix = "sha256="
+	nonceKeyPrefix  = "callback_nonce:"
+	maxNonceLength  = 128
+)
+
+// Sign computes the signature the ML server sends with a callback: an
+// HMAC-SHA256 over "<timestamp>.<nonce>.<body>", hex encoded and prefixed
+// with "sha256=".
+func Sign(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier authenticates ML callbacks. A callback must be signed with one of
+// the configured secrets, carry a timestamp within maxSkew of now, and use a
+// nonce that has not been seen before. Accepting more than one secret lets
+// the secret be rotated without dropping callbacks.
+//
+// Nonces are recorded in Redis so that every replica rejects a replay.
+// Without a Redis client they are recorded in process, per replica.
+type Verifier struct {
+	client  *redis.Client
+	secrets []string
+	maxSkew time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewVerifier ignores empty secrets; with none left, only callbacks signed
+// with the empty secret verify, which is meant for local development.
+func NewVerifier(client *redis.Client, secrets []string, maxSkew time.Duration) *Verifier {
+	active := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" {
+			active = append(active, secret)
+		}
+	}
+	if len(active) == 0 {
+		active = append(active, "")
+	}
+
+	return &Verifier{
+		client:  client,
+		secrets: active,
+		maxSkew: maxSkew,
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+func (v *Verifier) Verify(ctx context.Context, timestamp, nonce, signature string, body []byte) error {
+	if timestamp == "" || nonce == "" || signature == "" {
+		return apperrors.Forbidden("Missing callback signature")
+	}
+	if len(nonce) > maxNonceLength {
+		return apperrors.Forbidden("Invalid callback nonce")
+	}
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return apperrors.Forbidden("Invalid callback timestamp")
+	}
+	if skew := time.Since(time.Unix(sentAt, 0)); skew > v.maxSkew || skew < -v.maxSkew {
+		return apperrors.Forbidden("Callback timestamp outside the accepted window")
+	}
+
+	if !v.validSignature(timestamp, nonce, signature, body) {
+		return apperrors.Forbidden("Invalid callback signature")
+	}
+
+	// Nonces are only recorded for authentic callbacks, and only need to be
+	// remembered for as long as their timestamp would still be accepted.
+	fresh, err := v.claimNonce(ctx, nonce)
+	if err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to check callback nonce")
+	}
+	if !fresh {
+		return apperrors.Forbidden("Callback has already been received")
+	}
+
+	return nil
+}
+
+func (v *Verifier) claimNonce(ctx context.Context, nonce string) (bool, error) {
+	if v.client != nil {
+		return v.client.SetNX(ctx, nonceKeyPrefix+nonce, 1, 2*v.maxSkew).Result()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	for seen, expiresAt := range v.nonces {
+		if now.After(expiresAt) {
+			delete(v.nonces, seen)
+		}
+	}
+	if _, ok := v.nonces[nonce]; ok {
+		return false, nil
+	}
+	v.nonces[nonce] = now.Add(2 * v.maxSkew)
+	return true, nil
+}
+
+// validSignature compares against every secret in constant time so that
+// neither the signature nor which secret matched leaks through timing.
+func (v *Verifier) validSignature(timestamp, nonce, signature string, body []byte) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+
+	valid := false
+	for _, secret := range v.secrets {
+		expected := Sign(secret, timestamp, nonce, body)
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -6,25 +6,114 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/truegul/api-server/internal/schema"
 )
 
 type Config struct {
-	Port             string
-	DatabaseURL      string
-	RedisURL         string
+	Port        string
+	DatabaseURL string
+	// RedisURL is empty when the deployment runs without Redis; rate limits,
+	// login throttling, callback nonces and analysis events then stay in
+	// process.
+	RedisURL string
+	// MQBackend names the registered mq backend tasks are published on.
+	MQBackend        string
 	JWTSecret        string
 	JWTExpiry        time.Duration
+	RefreshTokenTTL  time.Duration
 	MLServerURL      string
 	MLCallbackSecret string
-	Environment      string
-	StreamName       string
-	CallbackBaseURL  string
-	CallbackPath     string
-	CORSOrigins      []string
+	// MLCallbackSecretPrevious is still accepted while the ML servers move
+	// to a rotated MLCallbackSecret.
+	MLCallbackSecretPrevious string
+	CallbackMaxSkew          time.Duration
+	Environment              string
+	StreamName               string
+	StreamGroup              string
+	ControlStream            string
+	// TaskSchemaVersion is the task format negotiated with the workers from
+	// ML_SCHEMA_VERSIONS.
+	TaskSchemaVersion string
+	// StreamMaxLen trims the task stream to roughly this many entries; zero,
+	// the default, leaves it unbounded. Trimming drops the oldest entries
+	// whether or not a worker has read them yet. The dead-letter stream is
+	// never trimmed.
+	StreamMaxLen int64
+	// TaskMaxDeliveries is how often a task may be delivered, across its
+	// attempts, before the reaper dead-letters it instead of re-queueing it.
+	TaskMaxDeliveries int64
+	CallbackBaseURL   string
+	CallbackPath      string
+	// CallbackHTTPEnabled exposes the callback endpoint and hands the ML
+	// server a callback URL. Turn it off when results come back on the
+	// results stream instead.
+	CallbackHTTPEnabled bool
+	ResultsStream       string
+	ResultsGroup        string
+	ResultsConsumer     string
+	ResultsClaimIdle    time.Duration
+	// ResultsMaxDeliveries is how often a result may be delivered before the
+	// results consumer stops retrying it and fails its analysis.
+	ResultsMaxDeliveries int64
+	CORSOrigins          []string
+	// TrustedProxies lists the proxy addresses or CIDRs whose
+	// X-Forwarded-For headers are believed. Empty trusts none, so the
+	// client IP is the peer address.
+	TrustedProxies []string
+
+	AIDetectionThreshold float64
+
+	RetrySchedulerInterval time.Duration
+	// ChannelForwardInterval paces handing channel-backend tasks to the ML
+	// server.
+	ChannelForwardInterval time.Duration
+	OutboxRelayInterval    time.Duration
+	// OutboxMaxAge is how long the relay keeps retrying a message before
+	// setting it aside.
+	OutboxMaxAge       time.Duration
+	ReaperInterval     time.Duration
+	AnalysisTimeout    time.Duration
+	TrashPurgeInterval time.Duration
+	TrashRetention     time.Duration
+	// ResultsConsumerInterval paces the results stream reader; zero leaves
+	// it off.
+	ResultsConsumerInterval time.Duration
+
+	AuthRateLimitPerMinute   int
+	AuthRateLimitBurst       int
+	SubmitRateLimitPerMinute int
+	SubmitRateLimitBurst     int
+
+	LoginIPMaxFailures int
+	LoginFailureWindow time.Duration
+	LoginLockout       time.Duration
 }
 
 func Load() *Config {
 	jwtExpiryHours, _ := strconv.Atoi(getEnv("JWT_EXPIRY_HOURS", "1"))
+	refreshTokenTTLDays, _ := strconv.Atoi(getEnv("REFRESH_TOKEN_TTL_DAYS", "30"))
+	retrySchedulerIntervalMs, _ := strconv.Atoi(getEnv("RETRY_SCHEDULER_INTERVAL_MS", "1000"))
+	channelForwardIntervalMs, _ := strconv.Atoi(getEnv("CHANNEL_FORWARD_INTERVAL_MS", "200"))
+	outboxRelayIntervalMs, _ := strconv.Atoi(getEnv("OUTBOX_RELAY_INTERVAL_MS", "500"))
+	outboxMaxAgeHours, _ := strconv.Atoi(getEnv("OUTBOX_MAX_AGE_HOURS", "6"))
+	reaperIntervalSeconds, _ := strconv.Atoi(getEnv("REAPER_INTERVAL_SECONDS", "60"))
+	analysisTimeoutSeconds, _ := strconv.Atoi(getEnv("ANALYSIS_TIMEOUT_SECONDS", "600"))
+	trashPurgeIntervalMinutes, _ := strconv.Atoi(getEnv("TRASH_PURGE_INTERVAL_MINUTES", "60"))
+	trashRetentionDays, _ := strconv.Atoi(getEnv("TRASH_RETENTION_DAYS", "30"))
+	authRateLimitPerMinute, _ := strconv.Atoi(getEnv("AUTH_RATE_LIMIT_PER_MINUTE", "10"))
+	authRateLimitBurst, _ := strconv.Atoi(getEnv("AUTH_RATE_LIMIT_BURST", "5"))
+	submitRateLimitPerMinute, _ := strconv.Atoi(getEnv("SUBMIT_RATE_LIMIT_PER_MINUTE", "6"))
+	submitRateLimitBurst, _ := strconv.Atoi(getEnv("SUBMIT_RATE_LIMIT_BURST", "3"))
+	loginIPMaxFailures, _ := strconv.Atoi(getEnv("LOGIN_IP_MAX_FAILURES", "50"))
+	loginFailureWindowMinutes, _ := strconv.Atoi(getEnv("LOGIN_FAILURE_WINDOW_MINUTES", "15"))
+	loginLockoutMinutes, _ := strconv.Atoi(getEnv("LOGIN_LOCKOUT_MINUTES", "15"))
+	callbackMaxSkewSeconds, _ := strconv.Atoi(getEnv("CALLBACK_MAX_SKEW_SECONDS", "300"))
+	streamMaxLen, _ := strconv.ParseInt(getEnv("STREAM_MAX_LEN", "0"), 10, 64)
+	taskMaxDeliveries, _ := strconv.ParseInt(getEnv("TASK_MAX_DELIVERIES", "3"), 10, 64)
+	resultsConsumerIntervalMs, _ := strconv.Atoi(getEnv("RESULTS_CONSUMER_INTERVAL_MS", "0"))
+	resultsClaimIdleSeconds, _ := strconv.Atoi(getEnv("RESULTS_CLAIM_IDLE_SECONDS", "60"))
+	resultsMaxDeliveries, _ := strconv.ParseInt(getEnv("RESULTS_MAX_DELIVERIES", "5"), 10, 64)
 
 	databaseURL := getEnv("DATABASE_URL", "")
 	if databaseURL == "" {
@@ -36,28 +125,133 @@ func Load() *Config {
 		log.Fatal("JWT_SECRET environment variable is required")
 	}
 
+	environment := getEnv("ENVIRONMENT", "development")
+	mlCallbackSecret := getEnv("ML_CALLBACK_SECRET", "")
+	if mlCallbackSecret == "" {
+		allowUnsigned, _ := strconv.ParseBool(getEnv("ALLOW_UNSIGNED_CALLBACKS", "false"))
+		if !allowUnsigned {
+			log.Fatal("ML_CALLBACK_SECRET environment variable is required (set ALLOW_UNSIGNED_CALLBACKS=true to run without it in development)")
+		}
+		if environment == "production" {
+			log.Fatal("ALLOW_UNSIGNED_CALLBACKS cannot be used in production")
+		}
+		log.Println("Warning: ML_CALLBACK_SECRET is not set; callbacks signed with an empty secret are accepted")
+	}
+
+	callbackHTTPEnabled, err := strconv.ParseBool(getEnv("CALLBACK_HTTP_ENABLED", "true"))
+	if err != nil {
+		log.Fatal("CALLBACK_HTTP_ENABLED must be a boolean")
+	}
+	if !callbackHTTPEnabled && resultsConsumerIntervalMs <= 0 {
+		log.Fatal("RESULTS_CONSUMER_INTERVAL_MS must be set when CALLBACK_HTTP_ENABLED is false")
+	}
+
+	// Redis is only required by the redis queue backend and the results
+	// stream; other deployments may leave REDIS_URL unset.
+	mqBackend := getEnv("MQ_BACKEND", "redis")
+	redisURL := getEnv("REDIS_URL", "")
+	if redisURL == "" && mqBackend == "redis" {
+		redisURL = "redis://localhost:6379"
+	}
+	if redisURL == "" && resultsConsumerIntervalMs > 0 {
+		log.Fatal("REDIS_URL must be set when RESULTS_CONSUMER_INTERVAL_MS is set")
+	}
+
+	resultsConsumer := getEnv("RESULTS_CONSUMER_NAME", "")
+	if resultsConsumer == "" {
+		// Each replica needs its own consumer name within the group.
+		resultsConsumer, _ = os.Hostname()
+	}
+	if resultsConsumer == "" {
+		resultsConsumer = "api-server"
+	}
+
+	taskSchemaVersion, err := schema.Negotiate(strings.Split(getEnv("ML_SCHEMA_VERSIONS", schema.V1), ","))
+	if err != nil {
+		log.Fatalf("ML_SCHEMA_VERSIONS: %v", err)
+	}
+
+	if outboxMaxAgeHours < 1 {
+		log.Fatal("OUTBOX_MAX_AGE_HOURS must be a positive integer")
+	}
+
 	port := getEnv("PORT", "8080")
 	callbackBaseURL := getEnv("CALLBACK_BASE_URL", "http://localhost:"+port)
 
+	aiDetectionThreshold, err := strconv.ParseFloat(getEnv("AI_DETECTION_THRESHOLD", "0.7"), 64)
+	if err != nil || aiDetectionThreshold < 0 || aiDetectionThreshold > 1 {
+		log.Fatal("AI_DETECTION_THRESHOLD must be a number between 0 and 1")
+	}
+
 	corsOriginsStr := getEnv("CORS_ORIGINS", "http://localhost:3000")
 	corsOrigins := strings.Split(corsOriginsStr, ",")
 	for i := range corsOrigins {
 		corsOrigins[i] = strings.TrimSpace(corsOrigins[i])
 	}
 
+	var trustedProxies []string
+	for _, proxy := range strings.Split(getEnv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+
 	return &Config{
 		Port:             port,
 		DatabaseURL:      databaseURL,
-		RedisURL:         getEnv("REDIS_URL", "redis://localhost:6379"),
+		RedisURL:         redisURL,
+		MQBackend:        mqBackend,
 		JWTSecret:        jwtSecret,
 		JWTExpiry:        time.Duration(jwtExpiryHours) * time.Hour,
+		RefreshTokenTTL:  time.Duration(refreshTokenTTLDays) * 24 * time.Hour,
 		MLServerURL:      getEnv("ML_SERVER_URL", "http://localhost:8000"),
-		MLCallbackSecret: getEnv("ML_CALLBACK_SECRET", ""),
-		Environment:      getEnv("ENVIRONMENT", "development"),
+		MLCallbackSecret: mlCallbackSecret,
+		Environment:      environment,
 		StreamName:       getEnv("STREAM_NAME", "analysis_tasks"),
-		CallbackBaseURL:  callbackBaseURL,
-		CallbackPath:     "/api/v1/internal/callback",
-		CORSOrigins:      corsOrigins,
+		StreamGroup:      getEnv("STREAM_GROUP", "analysis_workers"),
+		ControlStream:    getEnv("CONTROL_STREAM_NAME", "analysis_control"),
+
+		TaskSchemaVersion: taskSchemaVersion,
+		StreamMaxLen:      streamMaxLen,
+		TaskMaxDeliveries: taskMaxDeliveries,
+
+		CallbackBaseURL: callbackBaseURL,
+		CallbackPath:    "/api/v1/internal/callback",
+
+		CallbackHTTPEnabled:  callbackHTTPEnabled,
+		ResultsStream:        getEnv("RESULTS_STREAM_NAME", "analysis_results"),
+		ResultsGroup:         getEnv("RESULTS_STREAM_GROUP", "api_servers"),
+		ResultsConsumer:      resultsConsumer,
+		ResultsClaimIdle:     time.Duration(resultsClaimIdleSeconds) * time.Second,
+		ResultsMaxDeliveries: resultsMaxDeliveries,
+
+		CORSOrigins:    corsOrigins,
+		TrustedProxies: trustedProxies,
+
+		AIDetectionThreshold: aiDetectionThreshold,
+
+		RetrySchedulerInterval: time.Duration(retrySchedulerIntervalMs) * time.Millisecond,
+		ChannelForwardInterval: time.Duration(channelForwardIntervalMs) * time.Millisecond,
+		OutboxRelayInterval:    time.Duration(outboxRelayIntervalMs) * time.Millisecond,
+		OutboxMaxAge:           time.Duration(outboxMaxAgeHours) * time.Hour,
+		ReaperInterval:         time.Duration(reaperIntervalSeconds) * time.Second,
+		AnalysisTimeout:        time.Duration(analysisTimeoutSeconds) * time.Second,
+		TrashPurgeInterval:     time.Duration(trashPurgeIntervalMinutes) * time.Minute,
+		TrashRetention:         time.Duration(trashRetentionDays) * 24 * time.Hour,
+
+		ResultsConsumerInterval: time.Duration(resultsConsumerIntervalMs) * time.Millisecond,
+
+		MLCallbackSecretPrevious: getEnv("ML_CALLBACK_SECRET_PREVIOUS", ""),
+		CallbackMaxSkew:          time.Duration(callbackMaxSkewSeconds) * time.Second,
+
+		AuthRateLimitPerMinute:   authRateLimitPerMinute,
+		AuthRateLimitBurst:       authRateLimitBurst,
+		SubmitRateLimitPerMinute: submitRateLimitPerMinute,
+		SubmitRateLimitBurst:     submitRateLimitBurst,
+
+		LoginIPMaxFailures: loginIPMaxFailures,
+		LoginFailureWindow: time.Duration(loginFailureWindowMinutes) * time.Minute,
+		LoginLockout:       time.Duration(loginLockoutMinutes) * time.Minute,
 	}
 }
 
@@ -0,0 +1,31 @@
+package data
+
+type PlanFeature string
+
+const (
+	// PlanFeatureDetailedFeedback asks the ML worker for per-criterion
+	// feedback and deductions on top of the overall score.
+	PlanFeatureDetailedFeedback PlanFeature = "detailed_feedback"
+)
+
+// DefaultPlanCode is the plan new users start on.
+const DefaultPlanCode = "free"
+
+// Plan is the set of entitlements a user's submissions are checked against.
+type Plan struct {
+	Code                   string
+	Name                   string
+	DailySubmissionLimit   int
+	MonthlySubmissionLimit int
+	MaxContentLength       int
+	Features               []PlanFeature
+}
+
+func (p *Plan) HasFeature(feature PlanFeature) bool {
+	for _, f := range p.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"time"
+)
+
+// QuotaWindow is a user's submission allowance over one period of their
+// plan: their local day or their local month.
+type QuotaWindow struct {
+	Limit   int
+	Used    int
+	ResetAt time.Time
+}
+
+func (w QuotaWindow) Remaining() int {
+	if w.Used >= w.Limit {
+		return 0
+	}
+	return w.Limit - w.Used
+}
+
+// Quota is a user's submission allowance under their current plan.
+type Quota struct {
+	Plan    *Plan
+	Daily   QuotaWindow
+	Monthly QuotaWindow
+}
+
+// Binding returns the window that will refuse a submission first.
+func (q *Quota) Binding() QuotaWindow {
+	if q.Monthly.Remaining() < q.Daily.Remaining() {
+		return q.Monthly
+	}
+	return q.Daily
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionRevokedReason string
+
+const (
+	SessionRevokedLogout     SessionRevokedReason = "logout"
+	SessionRevokedTokenReuse SessionRevokedReason = "refresh_token_reuse"
+	SessionRevokedByUser     SessionRevokedReason = "revoked_by_user"
+	SessionRevokedEverywhere SessionRevokedReason = "logout_everywhere"
+)
+
+// Session is one login on one device.
+type Session struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	UserAgent     string
+	IPAddress     string
+	CreatedAt     time.Time
+	LastSeenAt    time.Time
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+	RevokedReason *SessionRevokedReason
+}
+
+// IsActive reports whether tokens issued to the session are still honored.
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -6,12 +6,30 @@ import (
 	"github.com/google/uuid"
 )
 
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
 type User struct {
-	ID               uuid.UUID
-	Email            string
-	PasswordHash     string
-	DailySubmitCount int
-	LastSubmitDate   *time.Time
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	ID                 uuid.UUID
+	Email              string
+	PasswordHash       string
+	Role               UserRole
+	Timezone           string
+	PlanCode           string
+	DailySubmitCount   int
+	MonthlySubmitCount int
+	LastSubmitDate     *time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// SubmissionUsage counts a user's submissions in their current local day and
+// month.
+type SubmissionUsage struct {
+	Daily   int
+	Monthly int
 }
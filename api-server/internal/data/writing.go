@@ -20,6 +20,24 @@ const (
 	WritingStatusAnalyzed  WritingStatus = "analyzed"
 )
 
+// writingTransitions lists the statuses each status may move to. A draft is
+// submitted for analysis and then analyzed; a failed analysis returns the
+// writing to draft, as does editing an analyzed writing to revise it.
+var writingTransitions = map[WritingStatus][]WritingStatus{
+	WritingStatusDraft:     {WritingStatusSubmitted},
+	WritingStatusSubmitted: {WritingStatusAnalyzed, WritingStatusDraft},
+	WritingStatusAnalyzed:  {WritingStatusDraft},
+}
+
+func (s WritingStatus) CanTransitionTo(next WritingStatus) bool {
+	for _, allowed := range writingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Writing struct {
 	ID          uuid.UUID
 	UserID      uuid.UUID
@@ -30,4 +48,17 @@ type Writing struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	SubmittedAt *time.Time
+	DeletedAt   *time.Time
+}
+
+// WritingRevision is the immutable snapshot of a writing taken when it is
+// submitted for analysis.
+type WritingRevision struct {
+	ID             uuid.UUID
+	WritingID      uuid.UUID
+	RevisionNumber int
+	Type           WritingType
+	Title          string
+	Content        string
+	CreatedAt      time.Time
 }
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ConnectRedis opens the Redis connection shared by rate limiting, login
+// throttling, callback nonces and analysis events. It is separate from the
+// message queue, which may use a different backend.
+func ConnectRedis(redisURL string) (*redis.Client, error) {
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opt)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	log.Println("Redis connected successfully")
+	return client, nil
+}
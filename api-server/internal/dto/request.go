@@ -3,6 +3,11 @@ package dto
 type SignupRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8,max=72"`
+	Timezone string `json:"timezone" binding:"omitempty,timezone"`
+}
+
+type UpdateTimezoneRequest struct {
+	Timezone string `json:"timezone" binding:"required,timezone"`
 }
 
 type LoginRequest struct {
@@ -13,13 +18,13 @@ type LoginRequest struct {
 type CreateWritingRequest struct {
 	Type    string `json:"type" binding:"required,oneof=essay cover_letter"`
 	Title   string `json:"title" binding:"required,min=1,max=255"`
-	Content string `json:"content" binding:"required,max=2000"`
+	Content string `json:"content" binding:"required"`
 }
 
 type UpdateWritingRequest struct {
 	Type    string `json:"type" binding:"omitempty,oneof=essay cover_letter"`
 	Title   string `json:"title" binding:"omitempty,min=1,max=255"`
-	Content string `json:"content" binding:"omitempty,max=2000"`
+	Content string `json:"content"`
 }
 
 type ListWritingsQuery struct {
@@ -27,22 +32,30 @@ type ListWritingsQuery struct {
 	Limit int `form:"limit,default=10" binding:"min=1,max=100"`
 }
 
-type AnalysisCallbackResult struct {
-	AIProbability float64 `json:"ai_probability"`
-	Feedback      string  `json:"feedback"`
-	LatencyMs     int     `json:"latency_ms"`
+type ListAnalysesQuery struct {
+	Page  int `form:"page,default=1" binding:"min=1"`
+	Limit int `form:"limit,default=10" binding:"min=1,max=100"`
+}
+
+type AnalysisDiffQuery struct {
+	From string `form:"from" binding:"required,uuid"`
+	To   string `form:"to" binding:"required,uuid"`
+}
+
+type ListAnalysisLogsQuery struct {
+	AnalysisID   string `form:"analysis_id" binding:"omitempty,uuid"`
+	ModelVersion string `form:"model_version" binding:"omitempty,max=50"`
+	From         string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To           string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+	Page         int    `form:"page,default=1" binding:"min=1"`
+	Limit        int    `form:"limit,default=20" binding:"min=1,max=100"`
 }
 
-type AnalysisCallbackError struct {
-	Code      string `json:"code"`
-	Message   string `json:"message"`
-	Retryable bool   `json:"retryable"`
+type ListDeadLettersQuery struct {
+	Before string `form:"before" binding:"omitempty,max=64"`
+	Limit  int    `form:"limit,default=20" binding:"min=1,max=100"`
 }
 
-type AnalysisCallbackRequest struct {
-	Version string                  `json:"version" binding:"required"`
-	TaskID  string                  `json:"task_id" binding:"required,uuid"`
-	Status  string                  `json:"status" binding:"required,oneof=completed failed"`
-	Result  *AnalysisCallbackResult `json:"result"`
-	Error   *AnalysisCallbackError  `json:"error"`
+type AssignPlanRequest struct {
+	PlanCode string `json:"plan_code" binding:"required,max=50"`
 }
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,8 +13,9 @@ type ErrorResponse struct {
 }
 
 type UserResponse struct {
-	ID    uuid.UUID `json:"id"`
-	Email string    `json:"email"`
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Timezone string    `json:"timezone,omitempty"`
 }
 
 type AuthResponse struct {
@@ -35,6 +37,7 @@ type WritingResponse struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type WritingListResponse struct {
@@ -45,20 +48,191 @@ type WritingListResponse struct {
 	TotalPages int               `json:"total_pages"`
 }
 
+type WritingRevisionResponse struct {
+	ID             uuid.UUID `json:"id"`
+	WritingID      uuid.UUID `json:"writing_id"`
+	RevisionNumber int       `json:"revision_number"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type WritingRevisionListResponse struct {
+	Revisions []WritingRevisionResponse `json:"revisions"`
+}
+
 type SubmitResponse struct {
 	Message    string    `json:"message"`
 	AnalysisID uuid.UUID `json:"analysis_id"`
 }
 
 type AnalysisResponse struct {
-	ID           uuid.UUID `json:"id"`
-	WritingID    uuid.UUID `json:"writing_id"`
-	Status       string    `json:"status"`
-	AIScore      *float64  `json:"ai_score,omitempty"`
-	Feedback     *string   `json:"feedback,omitempty"`
-	ErrorCode    *string   `json:"error_code,omitempty"`
-	ErrorMessage *string   `json:"error_message,omitempty"`
-	LatencyMs    *int      `json:"latency_ms,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uuid.UUID            `json:"id"`
+	WritingID    uuid.UUID            `json:"writing_id"`
+	RevisionID   *uuid.UUID           `json:"revision_id,omitempty"`
+	ContentHash  *string              `json:"content_hash,omitempty"`
+	Status       string               `json:"status"`
+	Stage        *string              `json:"stage,omitempty"`
+	Progress     *int                 `json:"progress,omitempty"`
+	AIScore      *float64             `json:"ai_score,omitempty"`
+	Feedback     *string              `json:"feedback,omitempty"`
+	Rubric       *RubricResponse      `json:"rubric,omitempty"`
+	AIDetection  *AIDetectionResponse `json:"ai_detection,omitempty"`
+	WarningLabel *string              `json:"warning_label,omitempty"`
+	ErrorCode    *string              `json:"error_code,omitempty"`
+	ErrorMessage *string              `json:"error_message,omitempty"`
+	LatencyMs    *int                 `json:"latency_ms,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+type AnalysisListResponse struct {
+	Analyses   []AnalysisResponse `json:"analyses"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	Limit      int                `json:"limit"`
+	TotalPages int                `json:"total_pages"`
+}
+
+type ScoreDeltaResponse struct {
+	From  *float64 `json:"from"`
+	To    *float64 `json:"to"`
+	Delta *float64 `json:"delta"`
+}
+
+type CriterionDiffResponse struct {
+	Criterion       string             `json:"criterion"`
+	Score           ScoreDeltaResponse `json:"score"`
+	FromFeedback    *string            `json:"from_feedback,omitempty"`
+	ToFeedback      *string            `json:"to_feedback,omitempty"`
+	FeedbackChanged bool               `json:"feedback_changed"`
+}
+
+type DeductionResponse struct {
+	Criterion string `json:"criterion"`
+	Reason    string `json:"reason"`
+}
+
+type AnalysisDiffResponse struct {
+	From               AnalysisResponse        `json:"from"`
+	To                 AnalysisResponse        `json:"to"`
+	TotalScore         ScoreDeltaResponse      `json:"total_score"`
+	AIScore            ScoreDeltaResponse      `json:"ai_score"`
+	Criteria           []CriterionDiffResponse `json:"criteria"`
+	FeedbackChanged    bool                    `json:"feedback_changed"`
+	AddedSuggestions   []string                `json:"added_suggestions"`
+	RemovedSuggestions []string                `json:"removed_suggestions"`
+	AddedDeductions    []DeductionResponse     `json:"added_deductions"`
+	RemovedDeductions  []DeductionResponse     `json:"removed_deductions"`
+}
+
+type RubricCriterionResponse struct {
+	Criterion  string   `json:"criterion"`
+	Score      int      `json:"score"`
+	MaxScore   int      `json:"max_score"`
+	Feedback   *string  `json:"feedback,omitempty"`
+	Deductions []string `json:"deductions"`
+}
+
+type RubricResponse struct {
+	SchemaVersion string                    `json:"schema_version"`
+	Criteria      []RubricCriterionResponse `json:"criteria"`
+	TotalScore    int                       `json:"total_score"`
+	MaxTotalScore int                       `json:"max_total_score"`
+	LevelEstimate *string                   `json:"level_estimate,omitempty"`
+	Suggestions   []string                  `json:"suggestions"`
+}
+
+type AIDetectionResponse struct {
+	Score        float64   `json:"score"`
+	IsFlagged    bool      `json:"is_flagged"`
+	ModelVersion string    `json:"model_version"`
+	DetectedAt   time.Time `json:"detected_at"`
+}
+
+type AnalysisLogResponse struct {
+	ID           uuid.UUID       `json:"id"`
+	AnalysisID   uuid.UUID       `json:"analysis_id"`
+	RevisionID   *uuid.UUID      `json:"revision_id,omitempty"`
+	InputText    string          `json:"input_text"`
+	ModelVersion string          `json:"model_version"`
+	RawOutput    json.RawMessage `json:"raw_output,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type AnalysisLogListResponse struct {
+	Logs       []AnalysisLogResponse `json:"logs"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+type PlanResponse struct {
+	Code                   string   `json:"code"`
+	Name                   string   `json:"name"`
+	DailySubmissionLimit   int      `json:"daily_submission_limit"`
+	MonthlySubmissionLimit int      `json:"monthly_submission_limit"`
+	MaxContentLength       int      `json:"max_content_length"`
+	Features               []string `json:"features"`
+}
+
+type PlanListResponse struct {
+	Plans []PlanResponse `json:"plans"`
+}
+
+type UserPlanResponse struct {
+	UserID uuid.UUID    `json:"user_id"`
+	Plan   PlanResponse `json:"plan"`
+}
+
+type QuotaWindowResponse struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type QuotaResponse struct {
+	Plan    string              `json:"plan"`
+	Daily   QuotaWindowResponse `json:"daily"`
+	Monthly QuotaWindowResponse `json:"monthly"`
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+type DeadLetterResponse struct {
+	ID         string     `json:"id"`
+	MessageID  string     `json:"message_id"`
+	TaskID     *uuid.UUID `json:"task_id,omitempty"`
+	Payload    string     `json:"payload"`
+	Reason     string     `json:"reason"`
+	Deliveries int64      `json:"deliveries"`
+	DeadAt     time.Time  `json:"dead_at"`
+}
+
+// DeadLetterListResponse pages newest first; NextCursor is passed back as
+// before to fetch the next page and is empty on the last one.
+type DeadLetterListResponse struct {
+	DeadLetters []DeadLetterResponse `json:"dead_letters"`
+	Total       int64                `json:"total"`
+	NextCursor  string               `json:"next_cursor,omitempty"`
+}
+
+type PurgeResponse struct {
+	Purged int64 `json:"purged"`
 }
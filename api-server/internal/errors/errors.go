@@ -3,6 +3,7 @@ package errors
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 type AppError struct {
@@ -10,6 +11,9 @@ type AppError struct {
 	Message    string
 	HTTPStatus int
 	Err        error
+	// RetryAfter tells the client how long to wait before trying again. It is
+	// only set on rate-limit errors.
+	RetryAfter time.Duration
 }
 
 func (e *AppError) Error() string {
@@ -23,6 +27,15 @@ func (e *AppError) Unwrap() error {
 	return e.Err
 }
 
+// RetryAfterSeconds is RetryAfter rounded up to whole seconds, as sent in the
+// Retry-After header. It is zero when the error carries no retry hint.
+func (e *AppError) RetryAfterSeconds() int {
+	if e.RetryAfter <= 0 {
+		return 0
+	}
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
 func New(code string, message string, httpStatus int) *AppError {
 	return &AppError{
 		Code:       code,
@@ -48,6 +61,10 @@ const (
 	CodeConflict       = "CONFLICT"
 	CodeInternalServer = "INTERNAL_SERVER_ERROR"
 	CodeContentTooLong = "CONTENT_TOO_LONG"
+	CodeWritingLocked  = "WRITING_LOCKED"
+	CodeInvalidState   = "INVALID_STATUS_TRANSITION"
+	CodeRateLimited    = "RATE_LIMITED"
+	CodeNotImplemented = "NOT_IMPLEMENTED"
 )
 
 func Validation(message string) *AppError {
@@ -82,6 +99,24 @@ func ContentTooLong(message string) *AppError {
 	return New(CodeContentTooLong, message, http.StatusBadRequest)
 }
 
+func WritingLocked(message string) *AppError {
+	return New(CodeWritingLocked, message, http.StatusConflict)
+}
+
+func InvalidTransition(message string) *AppError {
+	return New(CodeInvalidState, message, http.StatusConflict)
+}
+
+func RateLimited(message string, retryAfter time.Duration) *AppError {
+	err := New(CodeRateLimited, message, http.StatusTooManyRequests)
+	err.RetryAfter = retryAfter
+	return err
+}
+
+func NotImplemented(message string) *AppError {
+	return New(CodeNotImplemented, message, http.StatusNotImplemented)
+}
+
 func IsAppError(err error) (*AppError, bool) {
 	if appErr, ok := err.(*AppError); ok {
 		return appErr, true
@@ -0,0 +1,28 @@
+package events
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+// Broker fans analysis state changes out to every api-server replica so that
+// clients streaming a writing's analysis see transitions regardless of which
+// replica processed the callback.
+type Broker interface {
+	PublishAnalysis(ctx context.Context, analysis *model.Analysis) error
+	SubscribeAnalysis(ctx context.Context, writingID uuid.UUID) (*Subscription, error)
+}
+
+// Subscription delivers analysis snapshots until Close is called or the
+// subscribing context is cancelled.
+type Subscription struct {
+	C     <-chan *model.Analysis
+	close func() error
+}
+
+func (s *Subscription) Close() error {
+	return s.close()
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+// subscriberBuffer is how many snapshots a slow client may fall behind by.
+// Past that the oldest pending snapshot is dropped; each one is a full
+// state, so the client only misses intermediate transitions.
+const subscriberBuffer = 8
+
+// hub fans analysis snapshots out to the subscribers in this process.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan *model.Analysis]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[uuid.UUID]map[chan *model.Analysis]struct{})}
+}
+
+// subscribe registers a subscriber for the writing until the returned
+// subscription is closed or ctx is done.
+func (h *hub) subscribe(ctx context.Context, writingID uuid.UUID) *Subscription {
+	out := make(chan *model.Analysis, subscriberBuffer)
+	h.mu.Lock()
+	if h.subscribers[writingID] == nil {
+		h.subscribers[writingID] = make(map[chan *model.Analysis]struct{})
+	}
+	h.subscribers[writingID][out] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() error {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subscribers[writingID], out)
+			if len(h.subscribers[writingID]) == 0 {
+				delete(h.subscribers, writingID)
+			}
+			close(out)
+		})
+		return nil
+	}
+	stop := context.AfterFunc(ctx, func() { _ = unsubscribe() })
+
+	return &Subscription{C: out, close: func() error {
+		stop()
+		return unsubscribe()
+	}}
+}
+
+func (h *hub) publish(writingID uuid.UUID, analysis *model.Analysis) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for out := range h.subscribers[writingID] {
+		deliver(out, analysis)
+	}
+}
+
+// deliver hands the snapshot to a subscriber without blocking the publisher,
+// dropping the subscriber's oldest snapshot if it is full.
+func deliver(out chan *model.Analysis, analysis *model.Analysis) {
+	for {
+		select {
+		case out <- analysis:
+			return
+		default:
+		}
+		select {
+		case <-out:
+		default:
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+// LocalBroker delivers analysis events within this process only. It serves
+// deployments without Redis, which run a single api-server replica.
+type LocalBroker struct {
+	hub *hub
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{hub: newHub()}
+}
+
+func (b *LocalBroker) PublishAnalysis(ctx context.Context, analysis *model.Analysis) error {
+	snapshot := *analysis
+	b.hub.publish(analysis.WritingID, &snapshot)
+	return nil
+}
+
+func (b *LocalBroker) SubscribeAnalysis(ctx context.Context, writingID uuid.UUID) (*Subscription, error) {
+	return b.hub.subscribe(ctx, writingID), nil
+}
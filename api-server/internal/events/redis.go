@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+const analysisChannelPrefix = "analysis_events:"
+
+// RedisBroker publishes analysis events on per-writing Redis channels. Each
+// process holds a single pattern subscription, opened with the first
+// subscriber, and fans events out to its local subscribers.
+type RedisBroker struct {
+	client *redis.Client
+	hub    *hub
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{
+		client: client,
+		hub:    newHub(),
+	}
+}
+
+func (b *RedisBroker) PublishAnalysis(ctx context.Context, analysis *model.Analysis) error {
+	payload, err := json.Marshal(analysis)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, analysisChannel(analysis.WritingID), payload).Err()
+}
+
+func (b *RedisBroker) SubscribeAnalysis(ctx context.Context, writingID uuid.UUID) (*Subscription, error) {
+	if err := b.listen(ctx); err != nil {
+		return nil, err
+	}
+	return b.hub.subscribe(ctx, writingID), nil
+}
+
+// listen opens the shared pattern subscription if it is not open yet.
+// Waiting for the confirmation guarantees that no event published after
+// SubscribeAnalysis returns can be missed.
+func (b *RedisBroker) listen(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pubsub != nil {
+		return nil
+	}
+
+	pubsub := b.client.PSubscribe(context.Background(), analysisChannelPrefix+"*")
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+	b.pubsub = pubsub
+
+	go b.dispatch(pubsub.Channel())
+	return nil
+}
+
+func (b *RedisBroker) dispatch(messages <-chan *redis.Message) {
+	for msg := range messages {
+		writingID, err := uuid.Parse(strings.TrimPrefix(msg.Channel, analysisChannelPrefix))
+		if err != nil {
+			continue
+		}
+
+		var analysis model.Analysis
+		if err := json.Unmarshal([]byte(msg.Payload), &analysis); err != nil {
+			log.Printf("events: discard malformed analysis event: %v", err)
+			continue
+		}
+
+		b.hub.publish(writingID, &analysis)
+	}
+}
+
+func analysisChannel(writingID uuid.UUID) string {
+	return analysisChannelPrefix + writingID.String()
+}
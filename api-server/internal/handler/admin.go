@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+	"github.com/truegul/api-server/internal/dto"
+	"github.com/truegul/api-server/internal/model"
+	"github.com/truegul/api-server/internal/mq"
+	"github.com/truegul/api-server/internal/repository"
+	"github.com/truegul/api-server/internal/service"
+)
+
+// streamIDPattern matches Redis stream entry IDs, which identify dead
+// letters.
+var streamIDPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+type AdminHandler struct {
+	analysisService   *service.AnalysisService
+	planService       *service.PlanService
+	deadLetterService *service.DeadLetterService
+}
+
+func NewAdminHandler(analysisService *service.AnalysisService, planService *service.PlanService, deadLetterService *service.DeadLetterService) *AdminHandler {
+	return &AdminHandler{
+		analysisService:   analysisService,
+		planService:       planService,
+		deadLetterService: deadLetterService,
+	}
+}
+
+func (h *AdminHandler) ListAnalysisLogs(c *gin.Context) {
+	var query dto.ListAnalysisLogsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+
+	filter := repository.AnalysisLogFilter{
+		ModelVersion: query.ModelVersion,
+	}
+	if query.AnalysisID != "" {
+		analysisID := uuid.MustParse(query.AnalysisID)
+		filter.AnalysisID = &analysisID
+	}
+	if query.From != "" {
+		from, _ := time.Parse(time.DateOnly, query.From)
+		filter.From = &from
+	}
+	if query.To != "" {
+		// The end date is inclusive for callers.
+		to, _ := time.Parse(time.DateOnly, query.To)
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	logs, total, err := h.analysisService.ListLogs(filter, query.Page, query.Limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	logResponses := make([]dto.AnalysisLogResponse, len(logs))
+	for i := range logs {
+		logResponses[i] = toAnalysisLogResponse(&logs[i])
+	}
+
+	c.JSON(http.StatusOK, dto.AnalysisLogListResponse{
+		Logs:       logResponses,
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: totalPages(total, query.Limit),
+	})
+}
+
+func (h *AdminHandler) ListPlans(c *gin.Context) {
+	plans, err := h.planService.List()
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	planResponses := make([]dto.PlanResponse, len(plans))
+	for i, p := range plans {
+		planResponses[i] = toPlanResponse(p)
+	}
+
+	c.JSON(http.StatusOK, dto.PlanListResponse{
+		Plans: planResponses,
+	})
+}
+
+func (h *AdminHandler) AssignPlan(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		handleValidationError(c, "Invalid user ID")
+		return
+	}
+
+	var req dto.AssignPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+
+	plan, err := h.planService.Assign(userID, req.PlanCode)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.UserPlanResponse{
+		UserID: userID,
+		Plan:   toPlanResponse(plan),
+	})
+}
+
+func (h *AdminHandler) ListDeadLetters(c *gin.Context) {
+	var query dto.ListDeadLettersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+	if query.Before != "" && !streamIDPattern.MatchString(query.Before) {
+		handleValidationError(c, "Invalid cursor")
+		return
+	}
+
+	letters, total, err := h.deadLetterService.List(c.Request.Context(), query.Before, query.Limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	resp := dto.DeadLetterListResponse{
+		DeadLetters: make([]dto.DeadLetterResponse, len(letters)),
+		Total:       total,
+	}
+	for i := range letters {
+		resp.DeadLetters[i] = toDeadLetterResponse(&letters[i])
+	}
+	if len(letters) == query.Limit {
+		resp.NextCursor = letters[len(letters)-1].ID
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AdminHandler) GetDeadLetter(c *gin.Context) {
+	id, ok := deadLetterID(c)
+	if !ok {
+		return
+	}
+
+	letter, err := h.deadLetterService.Get(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toDeadLetterResponse(letter))
+}
+
+func (h *AdminHandler) ReplayDeadLetter(c *gin.Context) {
+	id, ok := deadLetterID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deadLetterService.Replay(c.Request.Context(), id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "Dead letter replayed"})
+}
+
+func (h *AdminHandler) PurgeDeadLetter(c *gin.Context) {
+	id, ok := deadLetterID(c)
+	if !ok {
+		return
+	}
+
+	purged, err := h.deadLetterService.Purge(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.PurgeResponse{Purged: purged})
+}
+
+func (h *AdminHandler) PurgeDeadLetters(c *gin.Context) {
+	purged, err := h.deadLetterService.Purge(c.Request.Context(), "")
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.PurgeResponse{Purged: purged})
+}
+
+func deadLetterID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if !streamIDPattern.MatchString(id) {
+		handleValidationError(c, "Invalid dead letter ID")
+		return "", false
+	}
+	return id, true
+}
+
+func toDeadLetterResponse(l *mq.DeadLetter) dto.DeadLetterResponse {
+	resp := dto.DeadLetterResponse{
+		ID:         l.ID,
+		MessageID:  l.MessageID,
+		Payload:    string(l.Payload),
+		Reason:     l.Reason,
+		Deliveries: l.Deliveries,
+		DeadAt:     l.DeadAt,
+	}
+	if l.TaskID != uuid.Nil {
+		taskID := l.TaskID
+		resp.TaskID = &taskID
+	}
+	return resp
+}
+
+func toPlanResponse(p *data.Plan) dto.PlanResponse {
+	features := make([]string, len(p.Features))
+	for i, f := range p.Features {
+		features[i] = string(f)
+	}
+
+	return dto.PlanResponse{
+		Code:                   p.Code,
+		Name:                   p.Name,
+		DailySubmissionLimit:   p.DailySubmissionLimit,
+		MonthlySubmissionLimit: p.MonthlySubmissionLimit,
+		MaxContentLength:       p.MaxContentLength,
+		Features:               features,
+	}
+}
+
+func toAnalysisLogResponse(l *model.AnalysisLog) dto.AnalysisLogResponse {
+	resp := dto.AnalysisLogResponse{
+		ID:           l.ID,
+		AnalysisID:   l.AnalysisID,
+		RevisionID:   l.RevisionID,
+		ModelVersion: l.ModelVersion,
+		CreatedAt:    l.CreatedAt,
+	}
+	switch {
+	case l.InputText != nil:
+		resp.InputText = *l.InputText
+	case l.Revision != nil:
+		resp.InputText = l.Revision.Content
+	}
+	if l.RawOutput != nil {
+		resp.RawOutput = json.RawMessage(*l.RawOutput)
+	}
+	return resp
+}
@@ -1,18 +1,34 @@
 package handler
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/truegul/api-server/internal/config"
+	"github.com/truegul/api-server/internal/data"
 	"github.com/truegul/api-server/internal/dto"
 	"github.com/truegul/api-server/internal/model"
 	"github.com/truegul/api-server/internal/service"
 )
 
-const CallbackSecretHeader = "X-Callback-Secret"
+const eventsKeepAliveInterval = 15 * time.Second
+
+// Submission quota headers. The reset is a Unix timestamp in seconds.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// AIDetectionWarningLabel is attached to analyses whose AI-detection score
+// crossed the flag threshold. Scoring still proceeds for flagged writings.
+const AIDetectionWarningLabel = "ai_suspected"
 
 type AnalysisHandler struct {
 	analysisService *service.AnalysisService
@@ -27,7 +43,7 @@ func NewAnalysisHandler(analysisService *service.AnalysisService, cfg *config.Co
 }
 
 func (h *AnalysisHandler) Submit(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			ErrorCode: "UNAUTHORIZED",
@@ -47,6 +63,7 @@ func (h *AnalysisHandler) Submit(c *gin.Context) {
 	}
 
 	analysis, err := h.analysisService.SubmitWriting(c.Request.Context(), writingID, userID.(uuid.UUID))
+	h.setRateLimitHeaders(c, userID.(uuid.UUID))
 	if err != nil {
 		handleError(c, err)
 		return
@@ -59,7 +76,7 @@ func (h *AnalysisHandler) Submit(c *gin.Context) {
 }
 
 func (h *AnalysisHandler) GetAnalysis(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			ErrorCode: "UNAUTHORIZED",
@@ -87,54 +104,180 @@ func (h *AnalysisHandler) GetAnalysis(c *gin.Context) {
 	c.JSON(http.StatusOK, toAnalysisResponse(analysis))
 }
 
-func (h *AnalysisHandler) Callback(c *gin.Context) {
-	secret := c.GetHeader(CallbackSecretHeader)
-	if secret != h.config.MLCallbackSecret {
-		c.JSON(http.StatusForbidden, dto.ErrorResponse{
-			ErrorCode: "FORBIDDEN",
-			Message:   "Invalid callback secret",
-		})
+func (h *AnalysisHandler) Quota(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	quota, err := h.analysisService.Quota(userID)
+	if err != nil {
+		handleError(c, err)
 		return
 	}
 
-	var req dto.AnalysisCallbackRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			ErrorCode: "VALIDATION_ERROR",
-			Message:   err.Error(),
-		})
+	c.JSON(http.StatusOK, dto.QuotaResponse{
+		Plan:    quota.Plan.Code,
+		Daily:   toQuotaWindowResponse(quota.Daily),
+		Monthly: toQuotaWindowResponse(quota.Monthly),
+	})
+}
+
+// setRateLimitHeaders reports the submission window that will run out first.
+// The headers are best effort and are skipped if the quota cannot be read.
+func (h *AnalysisHandler) setRateLimitHeaders(c *gin.Context, userID uuid.UUID) {
+	quota, err := h.analysisService.Quota(userID)
+	if err != nil {
 		return
 	}
 
-	taskID, err := uuid.Parse(req.TaskID)
+	window := quota.Binding()
+	c.Header(RateLimitLimitHeader, strconv.Itoa(window.Limit))
+	c.Header(RateLimitRemainingHeader, strconv.Itoa(window.Remaining()))
+	c.Header(RateLimitResetHeader, strconv.FormatInt(window.ResetAt.Unix(), 10))
+}
+
+func (h *AnalysisHandler) Cancel(c *gin.Context) {
+	writingID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			ErrorCode: "VALIDATION_ERROR",
-			Message:   "Invalid task ID",
-		})
+		handleValidationError(c, "Invalid writing ID")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	analysis, err := h.analysisService.CancelAnalysis(c.Request.Context(), writingID, userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toAnalysisResponse(analysis))
+}
+
+func (h *AnalysisHandler) ListAnalyses(c *gin.Context) {
+	writingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		handleValidationError(c, "Invalid writing ID")
+		return
+	}
+
+	var query dto.ListAnalysesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	analyses, total, err := h.analysisService.ListAnalyses(writingID, userID, query.Page, query.Limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	analysisResponses := make([]dto.AnalysisResponse, len(analyses))
+	for i := range analyses {
+		analysisResponses[i] = toAnalysisResponse(&analyses[i])
+	}
+
+	c.JSON(http.StatusOK, dto.AnalysisListResponse{
+		Analyses:   analysisResponses,
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: totalPages(total, query.Limit),
+	})
+}
+
+func (h *AnalysisHandler) DiffAnalyses(c *gin.Context) {
+	writingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		handleValidationError(c, "Invalid writing ID")
+		return
+	}
+
+	var query dto.AnalysisDiffQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	diff, err := h.analysisService.DiffAnalyses(writingID, userID, uuid.MustParse(query.From), uuid.MustParse(query.To))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toAnalysisDiffResponse(diff))
+}
+
+// Events streams the writing's analysis as Server-Sent Events. The current
+// state is sent first, followed by every transition until the analysis
+// reaches a terminal state or the client disconnects.
+func (h *AnalysisHandler) Events(c *gin.Context) {
+	writingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		handleValidationError(c, "Invalid writing ID")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	ctx := c.Request.Context()
+	current, sub, err := h.analysisService.SubscribeAnalysis(ctx, writingID, userID)
+	if err != nil {
+		handleError(c, err)
 		return
 	}
+	defer sub.Close()
 
-	var result *service.CallbackResult
-	var callbackErr *service.CallbackError
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
 
-	if req.Result != nil {
-		result = &service.CallbackResult{
-			AIProbability: req.Result.AIProbability,
-			Feedback:      req.Result.Feedback,
-			LatencyMs:     req.Result.LatencyMs,
+	if current != nil {
+		c.SSEvent("analysis", toAnalysisResponse(current))
+		c.Writer.Flush()
+		if current.Status.IsTerminal() {
+			return
 		}
 	}
 
-	if req.Error != nil {
-		callbackErr = &service.CallbackError{
-			Code:      req.Error.Code,
-			Message:   req.Error.Message,
-			Retryable: req.Error.Retryable,
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		case analysis, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent("analysis", toAnalysisResponse(analysis))
+			return !analysis.Status.IsTerminal()
 		}
+	})
+}
+
+// Callback must be mounted behind CallbackAuthMiddleware, which verifies the
+// ML server's signature before the body is parsed.
+func (h *AnalysisHandler) Callback(c *gin.Context) {
+	var raw []byte
+	if body, ok := c.Get(gin.BodyBytesKey); ok {
+		raw, _ = body.([]byte)
 	}
 
-	if err := h.analysisService.HandleCallback(taskID, req.Status, result, callbackErr); err != nil {
+	cb, err := service.DecodeCallback(raw)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := h.analysisService.HandleCallback(c.Request.Context(), cb); err != nil {
 		handleError(c, err)
 		return
 	}
@@ -144,14 +287,22 @@ func (h *AnalysisHandler) Callback(c *gin.Context) {
 
 func toAnalysisResponse(a *model.Analysis) dto.AnalysisResponse {
 	resp := dto.AnalysisResponse{
-		ID:        a.ID,
-		WritingID: a.WritingID,
-		Status:    string(a.Status),
-		AIScore:   a.AIScore,
-		Feedback:  a.Feedback,
-		LatencyMs: a.LatencyMs,
-		CreatedAt: a.CreatedAt,
-		UpdatedAt: a.UpdatedAt,
+		ID:          a.ID,
+		WritingID:   a.WritingID,
+		RevisionID:  a.RevisionID,
+		ContentHash: a.ContentHash,
+		Status:      string(a.Status),
+		Progress:    a.Progress,
+		AIScore:     a.AIScore,
+		Feedback:    a.Feedback,
+		LatencyMs:   a.LatencyMs,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+
+	if a.Stage != nil {
+		stage := string(*a.Stage)
+		resp.Stage = &stage
 	}
 
 	if a.ErrorCode != nil {
@@ -160,5 +311,121 @@ func toAnalysisResponse(a *model.Analysis) dto.AnalysisResponse {
 	}
 	resp.ErrorMessage = a.ErrorMessage
 
+	if a.Score != nil {
+		resp.Rubric = toRubricResponse(a.Score)
+	}
+
+	if d := a.AIDetection; d != nil {
+		resp.AIDetection = &dto.AIDetectionResponse{
+			Score:        d.Score,
+			IsFlagged:    d.IsFlagged,
+			ModelVersion: d.ModelVersion,
+			DetectedAt:   d.DetectedAt,
+		}
+		if d.IsFlagged {
+			label := AIDetectionWarningLabel
+			resp.WarningLabel = &label
+		}
+	}
+
 	return resp
 }
+
+func toRubricResponse(s *model.AnalysisScore) *dto.RubricResponse {
+	criteria := []struct {
+		name     model.RubricCriterion
+		score    int
+		maxScore int
+		feedback *string
+	}{
+		{model.RubricCriterionContent, s.ContentScore, model.MaxContentScore, s.ContentFeedback},
+		{model.RubricCriterionStructure, s.StructureScore, model.MaxStructureScore, s.StructureFeedback},
+		{model.RubricCriterionLanguage, s.LanguageScore, model.MaxLanguageScore, s.LanguageFeedback},
+	}
+
+	resp := &dto.RubricResponse{
+		SchemaVersion: s.SchemaVersion,
+		Criteria:      make([]dto.RubricCriterionResponse, 0, len(criteria)),
+		TotalScore:    s.TotalScore,
+		MaxTotalScore: model.MaxTotalScore,
+		LevelEstimate: s.LevelEstimate,
+		Suggestions:   s.Suggestions,
+	}
+	if resp.Suggestions == nil {
+		resp.Suggestions = []string{}
+	}
+
+	for _, c := range criteria {
+		deductions := []string{}
+		for _, d := range s.Deductions {
+			if d.Criterion == c.name {
+				deductions = append(deductions, d.Reason)
+			}
+		}
+
+		resp.Criteria = append(resp.Criteria, dto.RubricCriterionResponse{
+			Criterion:  string(c.name),
+			Score:      c.score,
+			MaxScore:   c.maxScore,
+			Feedback:   c.feedback,
+			Deductions: deductions,
+		})
+	}
+
+	return resp
+}
+
+func toAnalysisDiffResponse(d *service.AnalysisDiff) dto.AnalysisDiffResponse {
+	resp := dto.AnalysisDiffResponse{
+		From:               toAnalysisResponse(d.From),
+		To:                 toAnalysisResponse(d.To),
+		TotalScore:         toScoreDeltaResponse(d.TotalScore),
+		AIScore:            toScoreDeltaResponse(d.AIScore),
+		Criteria:           make([]dto.CriterionDiffResponse, len(d.Criteria)),
+		FeedbackChanged:    d.FeedbackChanged,
+		AddedSuggestions:   d.AddedSuggestions,
+		RemovedSuggestions: d.RemovedSuggestions,
+		AddedDeductions:    toDeductionResponses(d.AddedDeductions),
+		RemovedDeductions:  toDeductionResponses(d.RemovedDeductions),
+	}
+
+	for i, c := range d.Criteria {
+		resp.Criteria[i] = dto.CriterionDiffResponse{
+			Criterion:       string(c.Criterion),
+			Score:           toScoreDeltaResponse(c.Score),
+			FromFeedback:    c.FromFeedback,
+			ToFeedback:      c.ToFeedback,
+			FeedbackChanged: c.FeedbackChanged,
+		}
+	}
+
+	return resp
+}
+
+func toScoreDeltaResponse(d service.ScoreDelta) dto.ScoreDeltaResponse {
+	return dto.ScoreDeltaResponse{
+		From:  d.From,
+		To:    d.To,
+		Delta: d.Delta,
+	}
+}
+
+func toDeductionResponses(deductions []model.Deduction) []dto.DeductionResponse {
+	resp := make([]dto.DeductionResponse, len(deductions))
+	for i, d := range deductions {
+		resp[i] = dto.DeductionResponse{
+			Criterion: string(d.Criterion),
+			Reason:    d.Reason,
+		}
+	}
+	return resp
+}
+
+func toQuotaWindowResponse(w data.QuotaWindow) dto.QuotaWindowResponse {
+	return dto.QuotaWindowResponse{
+		Limit:     w.Limit,
+		Used:      w.Used,
+		Remaining: w.Remaining(),
+		ResetAt:   w.ResetAt,
+	}
+}
@@ -1,15 +1,23 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
 	"github.com/truegul/api-server/internal/dto"
 	apperrors "github.com/truegul/api-server/internal/errors"
 	"github.com/truegul/api-server/internal/service"
 )
 
+const (
+	RefreshTokenCookie     = "refresh_token"
+	refreshTokenCookiePath = "/api/v1/auth"
+)
+
 type AuthHandler struct {
 	authService  *service.AuthService
 	isProduction bool
@@ -29,15 +37,16 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Signup(req.Email, req.Password)
+	user, err := h.authService.Signup(req.Email, req.Password, req.Timezone)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, dto.UserResponse{
-		ID:    user.ID,
-		Email: user.Email,
+		ID:       user.ID,
+		Email:    user.Email,
+		Timezone: user.Timezone,
 	})
 }
 
@@ -48,39 +57,86 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.authService.Login(req.Email, req.Password)
+	user, tokens, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	csrfToken, err := service.GenerateCSRFToken()
+	h.respondWithSession(c, user, tokens)
+}
+
+// Refresh rotates the refresh token cookie and issues a new access token for
+// the same session.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	refreshToken, err := c.Cookie(RefreshTokenCookie)
+	if err != nil || refreshToken == "" {
+		handleError(c, apperrors.Unauthorized("Refresh token missing"))
+		return
+	}
+
+	user, tokens, err := h.authService.Refresh(refreshToken)
 	if err != nil {
+		h.clearSessionCookies(c)
 		handleError(c, err)
 		return
 	}
 
-	// Set SameSite=None for cross-origin cookie support
-	if h.isProduction {
-		c.SetSameSite(http.SameSiteNoneMode)
-	} else {
-		c.SetSameSite(http.SameSiteLaxMode)
+	h.respondWithSession(c, user, tokens)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	refreshToken, _ := c.Cookie(RefreshTokenCookie)
+	accessToken, _ := c.Cookie("token")
+	h.authService.Logout(refreshToken, accessToken)
+
+	h.clearSessionCookies(c)
+
+	c.JSON(http.StatusOK, dto.MessageResponse{
+		Message: "Logged out successfully",
+	})
+}
+
+func (h *AuthHandler) respondWithSession(c *gin.Context, user *data.User, tokens *service.AuthTokens) {
+	csrfToken, err := service.GenerateCSRFToken()
+	if err != nil {
+		handleError(c, err)
+		return
 	}
 
+	h.setSameSite(c)
+
+	accessMaxAge := int(time.Until(tokens.AccessExpiresAt).Seconds())
+	refreshMaxAge := int(time.Until(tokens.RefreshExpiresAt).Seconds())
+
 	c.SetCookie(
 		"token",
-		token,
-		3600,
+		tokens.AccessToken,
+		accessMaxAge,
 		"/",
 		"",
 		h.isProduction,
 		true,
 	)
 
+	// The refresh token is only ever sent to the auth endpoints.
+	c.SetCookie(
+		RefreshTokenCookie,
+		tokens.RefreshToken,
+		refreshMaxAge,
+		refreshTokenCookiePath,
+		"",
+		h.isProduction,
+		true,
+	)
+
 	c.SetCookie(
 		"csrf_token",
 		csrfToken,
-		3600,
+		refreshMaxAge,
 		"/",
 		"",
 		h.isProduction,
@@ -89,27 +145,30 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	c.JSON(http.StatusOK, dto.AuthResponse{
 		User: dto.UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
+			ID:       user.ID,
+			Email:    user.Email,
+			Timezone: user.Timezone,
 		},
 		CSRFToken: csrfToken,
 	})
 }
 
-func (h *AuthHandler) Logout(c *gin.Context) {
-	// Set SameSite=None for cross-origin cookie support
+func (h *AuthHandler) clearSessionCookies(c *gin.Context) {
+	h.setSameSite(c)
+
+	c.SetCookie("token", "", -1, "/", "", h.isProduction, true)
+	c.SetCookie(RefreshTokenCookie, "", -1, refreshTokenCookiePath, "", h.isProduction, true)
+	c.SetCookie("csrf_token", "", -1, "/", "", h.isProduction, false)
+}
+
+// setSameSite uses SameSite=None in production for cross-origin cookie
+// support.
+func (h *AuthHandler) setSameSite(c *gin.Context) {
 	if h.isProduction {
 		c.SetSameSite(http.SameSiteNoneMode)
 	} else {
 		c.SetSameSite(http.SameSiteLaxMode)
 	}
-
-	c.SetCookie("token", "", -1, "/", "", h.isProduction, true)
-	c.SetCookie("csrf_token", "", -1, "/", "", h.isProduction, false)
-
-	c.JSON(http.StatusOK, dto.MessageResponse{
-		Message: "Logged out successfully",
-	})
 }
 
 func (h *AuthHandler) Me(c *gin.Context) {
@@ -126,3 +185,94 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		Email: email.(string),
 	})
 }
+
+func (h *AuthHandler) UpdateTimezone(c *gin.Context) {
+	var req dto.UpdateTimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	user, err := h.authService.UpdateTimezone(userID, req.Timezone)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.UserResponse{
+		ID:       user.ID,
+		Email:    user.Email,
+		Timezone: user.Timezone,
+	})
+}
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	currentID := c.GetString("session_id")
+
+	sessions, err := h.authService.ListSessions(userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	sessionResponses := make([]dto.SessionResponse, len(sessions))
+	for i, s := range sessions {
+		sessionResponses[i] = dto.SessionResponse{
+			ID:         s.ID,
+			Device:     service.DeviceName(s.UserAgent),
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID.String() == currentID,
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.SessionListResponse{
+		Sessions: sessionResponses,
+	})
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		handleValidationError(c, "Invalid session ID")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.authService.RevokeSession(userID, sessionID); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if sessionID.String() == c.GetString("session_id") {
+		h.clearSessionCookies(c)
+	}
+
+	c.JSON(http.StatusOK, dto.MessageResponse{
+		Message: "Session revoked",
+	})
+}
+
+// RevokeAllSessions logs the user out everywhere, this device included.
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	revoked, err := h.authService.RevokeAllSessions(userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	h.clearSessionCookies(c)
+
+	c.JSON(http.StatusOK, dto.MessageResponse{
+		Message: fmt.Sprintf("Logged out of %d session(s)", revoked),
+	})
+}
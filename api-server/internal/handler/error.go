@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/truegul/api-server/internal/dto"
@@ -10,6 +11,9 @@ import (
 
 func handleError(c *gin.Context, err error) {
 	if appErr, ok := apperrors.IsAppError(err); ok {
+		if seconds := appErr.RetryAfterSeconds(); seconds > 0 {
+			c.Header("Retry-After", strconv.Itoa(seconds))
+		}
 		c.JSON(appErr.HTTPStatus, dto.ErrorResponse{
 			ErrorCode: appErr.Code,
 			Message:   appErr.Message,
@@ -8,11 +8,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+
+	"github.com/truegul/api-server/internal/mq"
 )
 
 type HealthHandler struct {
-	db     *gorm.DB
-	redis  *redis.Client
+	db        *gorm.DB
+	redis     *redis.Client
+	publisher mq.Publisher
 }
 
 type HealthResponse struct {
@@ -20,10 +23,11 @@ type HealthResponse struct {
 	Services map[string]string `json:"services"`
 }
 
-func NewHealthHandler(db *gorm.DB, redis *redis.Client) *HealthHandler {
+func NewHealthHandler(db *gorm.DB, redis *redis.Client, publisher mq.Publisher) *HealthHandler {
 	return &HealthHandler{
-		db:     db,
-		redis:  redis,
+		db:        db,
+		redis:     redis,
+		publisher: publisher,
 	}
 }
 
@@ -58,6 +62,14 @@ func (h *HealthHandler) Check(c *gin.Context) {
 		services["redis"] = "not configured"
 	}
 
+	// Check the message queue, whichever backend it is
+	if err := h.publisher.Health(ctx); err != nil {
+		services["queue"] = "unhealthy"
+		overallStatus = "unhealthy"
+	} else {
+		services["queue"] = "healthy"
+	}
+
 	status := http.StatusOK
 	if overallStatus != "healthy" {
 		status = http.StatusServiceUnavailable
@@ -75,17 +75,12 @@ func (h *WritingHandler) List(c *gin.Context) {
 		writingResponses[i] = toWritingResponse(w)
 	}
 
-	totalPages := int(total) / query.Limit
-	if int(total)%query.Limit != 0 {
-		totalPages++
-	}
-
 	c.JSON(http.StatusOK, dto.WritingListResponse{
 		Writings:   writingResponses,
 		Total:      total,
 		Page:       query.Page,
 		Limit:      query.Limit,
-		TotalPages: totalPages,
+		TotalPages: totalPages(total, query.Limit),
 	})
 }
 
@@ -142,7 +137,89 @@ func (h *WritingHandler) Delete(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, dto.MessageResponse{
-		Message: "Writing deleted successfully",
+		Message: "Writing moved to trash",
+	})
+}
+
+func (h *WritingHandler) ListTrash(c *gin.Context) {
+	var query dto.ListWritingsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		handleValidationError(c, err.Error())
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	writings, total, err := h.writingService.ListTrash(userID, query.Page, query.Limit)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	writingResponses := make([]dto.WritingResponse, len(writings))
+	for i, w := range writings {
+		writingResponses[i] = toWritingResponse(w)
+	}
+
+	c.JSON(http.StatusOK, dto.WritingListResponse{
+		Writings:   writingResponses,
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: totalPages(total, query.Limit),
+	})
+}
+
+func (h *WritingHandler) Restore(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		handleValidationError(c, "Invalid writing ID")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	writing, err := h.writingService.Restore(id, userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toWritingResponse(writing))
+}
+
+func (h *WritingHandler) ListRevisions(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		handleValidationError(c, "Invalid writing ID")
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	revisions, err := h.writingService.ListRevisions(id, userID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	revisionResponses := make([]dto.WritingRevisionResponse, len(revisions))
+	for i, r := range revisions {
+		revisionResponses[i] = dto.WritingRevisionResponse{
+			ID:             r.ID,
+			WritingID:      r.WritingID,
+			RevisionNumber: r.RevisionNumber,
+			Type:           string(r.Type),
+			Title:          r.Title,
+			Content:        r.Content,
+			CreatedAt:      r.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.WritingRevisionListResponse{
+		Revisions: revisionResponses,
 	})
 }
 
@@ -157,5 +234,14 @@ func toWritingResponse(w *data.Writing) dto.WritingResponse {
 		CreatedAt:   w.CreatedAt,
 		UpdatedAt:   w.UpdatedAt,
 		SubmittedAt: w.SubmittedAt,
+		DeletedAt:   w.DeletedAt,
+	}
+}
+
+func totalPages(total int64, limit int) int {
+	pages := int(total) / limit
+	if int(total)%limit != 0 {
+		pages++
 	}
+	return pages
 }
@@ -0,0 +1,88 @@
+package loginguard
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// localCounters is the in-process counterpart of failScript, used when the
+// api-server runs without Redis. Counts are per replica.
+type localCounters struct {
+	mu       sync.Mutex
+	counters map[string]*localCounter
+	swept    time.Time
+}
+
+type localCounter struct {
+	failures     int
+	blockedUntil time.Time
+	expiresAt    time.Time
+}
+
+func newLocalCounters() *localCounters {
+	return &localCounters{counters: make(map[string]*localCounter)}
+}
+
+func (c *localCounters) blockedUntil(key string, now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counter, ok := c.counters[key]
+	if !ok || now.After(counter.expiresAt) {
+		return time.Time{}
+	}
+	return counter.blockedUntil
+}
+
+// fail mirrors failScript: it returns the failure count, the blocked-until
+// time and whether this failure started a lockout.
+func (c *localCounters) fail(key string, policy Policy, now time.Time) (int, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now)
+
+	counter, ok := c.counters[key]
+	if !ok || now.After(counter.expiresAt) {
+		counter = &localCounter{}
+		c.counters[key] = counter
+	}
+	counter.failures++
+
+	locked := false
+	counter.blockedUntil = time.Time{}
+	switch {
+	case policy.Threshold > 0 && counter.failures >= policy.Threshold:
+		counter.blockedUntil = now.Add(policy.LockoutDuration)
+		locked = counter.failures == policy.Threshold
+	case counter.failures > policy.FreeAttempts:
+		delay := float64(policy.BaseDelay) * math.Pow(2, float64(counter.failures-policy.FreeAttempts-1))
+		counter.blockedUntil = now.Add(time.Duration(math.Min(float64(policy.MaxDelay), delay)))
+	}
+
+	counter.expiresAt = now.Add(policy.Window)
+	if counter.blockedUntil.After(counter.expiresAt) {
+		counter.expiresAt = counter.blockedUntil
+	}
+	return counter.failures, counter.blockedUntil, locked
+}
+
+func (c *localCounters) reset(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counters, key)
+}
+
+// sweep drops expired counters at most once a minute.
+func (c *localCounters) sweep(now time.Time) {
+	if now.Sub(c.swept) < time.Minute {
+		return
+	}
+	c.swept = now
+	for key, counter := range c.counters {
+		if now.After(counter.expiresAt) {
+			delete(c.counters, key)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package loginguard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "login_guard:"
+
+// Scope is what a run of failed logins is counted against.
+type Scope string
+
+const (
+	ScopeAccount Scope = "account"
+	ScopeIP      Scope = "ip"
+)
+
+// failScript counts one failed login and works out until when the client
+// must wait before trying again: nothing for the first FreeAttempts
+// failures, then a delay that doubles with every further failure, and a
+// lockout once Threshold is reached, if it is positive. It returns the
+// failure count, the blocked-until time in Unix milliseconds and 1 if this
+// failure started a lockout.
+var failScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local free = tonumber(ARGV[3])
+local base = tonumber(ARGV[4])
+local max_delay = tonumber(ARGV[5])
+local threshold = tonumber(ARGV[6])
+local lockout = tonumber(ARGV[7])
+
+local failures = redis.call('HINCRBY', KEYS[1], 'failures', 1)
+local blocked_until = 0
+local locked = 0
+
+if threshold > 0 and failures >= threshold then
+	blocked_until = now + lockout
+	if failures == threshold then
+		locked = 1
+	end
+elseif failures > free then
+	blocked_until = now + math.floor(math.min(max_delay, base * 2 ^ (failures - free - 1)))
+end
+
+redis.call('HSET', KEYS[1], 'blocked_until', blocked_until)
+redis.call('PEXPIRE', KEYS[1], math.max(window, blocked_until - now))
+
+return {failures, blocked_until, locked}
+`)
+
+// Policy configures how failures in one scope are throttled.
+type Policy struct {
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+	// FreeAttempts failures are tolerated before any delay applies.
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// Threshold failures lock the scope out for LockoutDuration. Zero never
+	// locks out, leaving only the progressive delay.
+	Threshold       int
+	LockoutDuration time.Duration
+}
+
+// Lockout reports a scope that has just been locked out.
+type Lockout struct {
+	Scope       Scope
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Guard tracks failed logins per account and per client IP in Redis, so
+// every api-server replica sees the same counts. Without a Redis client the
+// counts are kept in process, per replica. Accounts should only be delayed,
+// never locked out: anyone who knows an email could otherwise keep its owner
+// from logging in. Accounts are keyed by the hashed, normalized email whether
+// or not such an account exists, so the guard behaves identically for
+// unknown emails.
+type Guard struct {
+	client   *redis.Client
+	local    *localCounters
+	policies map[Scope]Policy
+}
+
+func NewGuard(client *redis.Client, account, ip Policy) *Guard {
+	g := &Guard{
+		client: client,
+		policies: map[Scope]Policy{
+			ScopeAccount: account,
+			ScopeIP:      ip,
+		},
+	}
+	if client == nil {
+		g.local = newLocalCounters()
+	}
+	return g
+}
+
+// Check returns how long the client must still wait before its next login
+// attempt, or zero when it may try now.
+func (g *Guard) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	keys := scopedKeys(email, ip)
+
+	if g.local != nil {
+		var wait time.Duration
+		now := time.Now()
+		for _, k := range keys {
+			if remaining := g.local.blockedUntil(k.key, now).Sub(now); remaining > wait {
+				wait = remaining
+			}
+		}
+		return wait, nil
+	}
+
+	pipe := g.client.Pipeline()
+	cmds := make([]*redis.StringCmd, 0, len(keys))
+	for _, k := range keys {
+		cmds = append(cmds, pipe.HGet(ctx, k.key, "blocked_until"))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	now := time.Now()
+	for _, cmd := range cmds {
+		blockedUntil, err := cmd.Int64()
+		if err != nil {
+			continue
+		}
+		if remaining := time.UnixMilli(blockedUntil).Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// RecordFailure counts a failed login against both the account and the IP
+// and returns any lockouts it triggered.
+func (g *Guard) RecordFailure(ctx context.Context, email, ip string) ([]Lockout, error) {
+	var lockouts []Lockout
+	now := time.Now()
+
+	for _, k := range scopedKeys(email, ip) {
+		policy := g.policies[k.scope]
+		if g.local != nil {
+			failures, blockedUntil, locked := g.local.fail(k.key, policy, now)
+			if locked {
+				lockouts = append(lockouts, Lockout{Scope: k.scope, Failures: failures, LockedUntil: blockedUntil})
+			}
+			continue
+		}
+
+		values, err := failScript.Run(ctx, g.client, []string{k.key},
+			now.UnixMilli(),
+			policy.Window.Milliseconds(),
+			policy.FreeAttempts,
+			policy.BaseDelay.Milliseconds(),
+			policy.MaxDelay.Milliseconds(),
+			policy.Threshold,
+			policy.LockoutDuration.Milliseconds(),
+		).Int64Slice()
+		if err != nil {
+			return lockouts, err
+		}
+
+		if values[2] == 1 {
+			lockouts = append(lockouts, Lockout{
+				Scope:       k.scope,
+				Failures:    int(values[0]),
+				LockedUntil: time.UnixMilli(values[1]),
+			})
+		}
+	}
+	return lockouts, nil
+}
+
+// Reset forgets the account's failures after a successful login. The IP's
+// failures are kept so that one valid account cannot be used to launder
+// guesses against others.
+func (g *Guard) Reset(ctx context.Context, email string) error {
+	if g.local != nil {
+		g.local.reset(accountKey(email))
+		return nil
+	}
+	return g.client.Del(ctx, accountKey(email)).Err()
+}
+
+type scopedKey struct {
+	scope Scope
+	key   string
+}
+
+func scopedKeys(email, ip string) []scopedKey {
+	return []scopedKey{
+		{scope: ScopeAccount, key: accountKey(email)},
+		{scope: ScopeIP, key: ipKey(ip)},
+	}
+}
+
+func accountKey(email string) string {
+	sum := sha256.Sum256([]byte(NormalizeEmail(email)))
+	return keyPrefix + string(ScopeAccount) + ":" + hex.EncodeToString(sum[:])
+}
+
+func ipKey(ip string) string {
+	return keyPrefix + string(ScopeIP) + ":" + ip
+}
+
+// NormalizeEmail is the form an email is tracked under.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
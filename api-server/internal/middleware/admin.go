@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+	"github.com/truegul/api-server/internal/dto"
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/service"
+)
+
+// AdminMiddleware must run after AuthMiddleware. The role is read from the
+// database rather than the token so that revoking admin rights takes effect
+// immediately.
+func AdminMiddleware(authService *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.ErrorResponse{
+				ErrorCode: apperrors.CodeUnauthorized,
+				Message:   "Authentication required",
+			})
+			return
+		}
+
+		user, err := authService.GetUserByID(userID.(uuid.UUID))
+		if err != nil || user.Role != data.UserRoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, dto.ErrorResponse{
+				ErrorCode: apperrors.CodeForbidden,
+				Message:   "Admin access required",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
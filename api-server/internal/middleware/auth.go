@@ -38,6 +38,7 @@ func AuthMiddleware(authService *service.AuthService) gin.HandlerFunc {
 
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("session_id", claims.ID)
 
 		c.Next()
 	}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truegul/api-server/internal/callbackauth"
+	"github.com/truegul/api-server/internal/dto"
+	apperrors "github.com/truegul/api-server/internal/errors"
+)
+
+// maxCallbackBodyBytes bounds how much of an unauthenticated body is read
+// before its signature can be checked.
+const maxCallbackBodyBytes = 1 << 20
+
+// CallbackAuthMiddleware verifies the ML server's signature over the raw
+// request body. The verified body is left in the context under
+// gin.BodyBytesKey, where ShouldBindBodyWith picks it up.
+func CallbackAuthMiddleware(verifier *callbackauth.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCallbackBodyBytes))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{
+				ErrorCode: apperrors.CodeValidation,
+				Message:   "Callback body too large",
+			})
+			return
+		}
+
+		err = verifier.Verify(
+			c.Request.Context(),
+			c.GetHeader(callbackauth.TimestampHeader),
+			c.GetHeader(callbackauth.NonceHeader),
+			c.GetHeader(callbackauth.SignatureHeader),
+			body,
+		)
+		if err != nil {
+			appErr, ok := apperrors.IsAppError(err)
+			if !ok {
+				appErr = apperrors.Forbidden("Invalid callback signature")
+			}
+			c.AbortWithStatusJSON(appErr.HTTPStatus, dto.ErrorResponse{
+				ErrorCode: appErr.Code,
+				Message:   appErr.Message,
+			})
+			return
+		}
+
+		c.Set(gin.BodyBytesKey, body)
+		c.Next()
+	}
+}
@@ -0,0 +1,13 @@
+-- Track every delivery attempt of an analysis task
+CREATE TABLE IF NOT EXISTS analysis_attempts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    analysis_id UUID NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    error_code VARCHAR(50),
+    error_message TEXT,
+    retryable BOOLEAN NOT NULL DEFAULT FALSE,
+    next_retry_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_analysis_attempts_analysis_id ON analysis_attempts(analysis_id);
@@ -0,0 +1,3 @@
+-- Progress reported by the ML worker while an analysis is processing
+ALTER TABLE analyses ADD COLUMN stage VARCHAR(50);
+ALTER TABLE analyses ADD COLUMN progress INTEGER CHECK (progress >= 0 AND progress <= 100);
@@ -0,0 +1,17 @@
+-- Per-criterion TOPIK 54 rubric scores
+CREATE TABLE IF NOT EXISTS analysis_scores (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    analysis_id UUID NOT NULL UNIQUE REFERENCES analyses(id) ON DELETE CASCADE,
+    schema_version VARCHAR(20) NOT NULL,
+    content_score INTEGER NOT NULL CHECK (content_score >= 0 AND content_score <= 20),
+    structure_score INTEGER NOT NULL CHECK (structure_score >= 0 AND structure_score <= 15),
+    language_score INTEGER NOT NULL CHECK (language_score >= 0 AND language_score <= 15),
+    total_score INTEGER NOT NULL CHECK (total_score >= 0 AND total_score <= 50),
+    content_feedback TEXT,
+    structure_feedback TEXT,
+    language_feedback TEXT,
+    deductions JSONB NOT NULL DEFAULT '[]',
+    suggestions JSONB NOT NULL DEFAULT '[]',
+    level_estimate VARCHAR(10),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
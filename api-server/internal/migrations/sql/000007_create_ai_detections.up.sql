@@ -0,0 +1,13 @@
+-- AI-written content detection results, kept separately from scoring
+CREATE TABLE IF NOT EXISTS ai_detections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    analysis_id UUID NOT NULL UNIQUE REFERENCES analyses(id) ON DELETE CASCADE,
+    score DECIMAL(5, 4) NOT NULL CHECK (score >= 0 AND score <= 1),
+    is_flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    threshold DECIMAL(5, 4) NOT NULL,
+    model_version VARCHAR(50) NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ai_detections_is_flagged ON ai_detections(is_flagged);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_analyses_revision_id;
+ALTER TABLE analyses DROP COLUMN revision_id;
+DROP TABLE IF EXISTS writing_revisions;
@@ -0,0 +1,27 @@
+-- Immutable snapshots of the content that was submitted for analysis
+CREATE TABLE IF NOT EXISTS writing_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    writing_id UUID NOT NULL REFERENCES writings(id) ON DELETE CASCADE,
+    revision_number INTEGER NOT NULL,
+    type VARCHAR(50) NOT NULL CHECK (type IN ('essay', 'cover_letter')),
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (writing_id, revision_number)
+);
+
+CREATE INDEX idx_writing_revisions_writing_id ON writing_revisions(writing_id);
+
+ALTER TABLE analyses ADD COLUMN revision_id UUID REFERENCES writing_revisions(id) ON DELETE SET NULL;
+CREATE INDEX idx_analyses_revision_id ON analyses(revision_id);
+
+-- Backfill a first revision for writings that were analyzed before revisions existed
+INSERT INTO writing_revisions (writing_id, revision_number, type, title, content, created_at)
+SELECT w.id, 1, w.type, w.title, w.content, COALESCE(w.submitted_at, w.created_at)
+FROM writings w
+WHERE EXISTS (SELECT 1 FROM analyses a WHERE a.writing_id = w.id);
+
+UPDATE analyses a
+SET revision_id = r.id
+FROM writing_revisions r
+WHERE r.writing_id = a.writing_id AND a.revision_id IS NULL;
@@ -0,0 +1,3 @@
+-- Soft deletion: trashed writings are purged after a retention window
+ALTER TABLE writings ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_writings_deleted_at ON writings(deleted_at);
@@ -0,0 +1,4 @@
+UPDATE analyses SET status = 'failed' WHERE status = 'cancelled';
+ALTER TABLE analyses DROP CONSTRAINT IF EXISTS analyses_status_check;
+ALTER TABLE analyses ADD CONSTRAINT analyses_status_check
+    CHECK (status IN ('pending', 'processing', 'completed', 'failed'));
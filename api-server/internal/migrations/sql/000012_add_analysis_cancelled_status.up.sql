@@ -0,0 +1,3 @@
+ALTER TABLE analyses DROP CONSTRAINT IF EXISTS analyses_status_check;
+ALTER TABLE analyses ADD CONSTRAINT analyses_status_check
+    CHECK (status IN ('pending', 'processing', 'completed', 'failed', 'cancelled'));
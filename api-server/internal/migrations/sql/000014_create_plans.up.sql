@@ -0,0 +1,29 @@
+-- Plans define what a user is entitled to; every user is on exactly one
+CREATE TABLE plans (
+    code VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    daily_submission_limit INTEGER NOT NULL CHECK (daily_submission_limit > 0),
+    monthly_submission_limit INTEGER NOT NULL CHECK (monthly_submission_limit > 0),
+    max_content_length INTEGER NOT NULL CHECK (max_content_length > 0),
+    features JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO plans (code, name, daily_submission_limit, monthly_submission_limit, max_content_length, features) VALUES
+    ('free', 'Free', 5, 60, 2000, '[]'),
+    ('pro', 'Pro', 20, 300, 5000, '["detailed_feedback"]');
+
+ALTER TABLE users ADD COLUMN plan_code VARCHAR(50) NOT NULL DEFAULT 'free' REFERENCES plans(code);
+ALTER TABLE users ADD COLUMN monthly_submit_count INTEGER NOT NULL DEFAULT 0;
+
+-- Seed the monthly counter from this month's submissions
+UPDATE users u SET monthly_submit_count = (
+    SELECT COUNT(*)
+    FROM analyses a
+    JOIN writings w ON w.id = a.writing_id
+    WHERE w.user_id = u.id
+      AND a.created_at >= date_trunc('month', NOW() AT TIME ZONE u.timezone) AT TIME ZONE u.timezone
+);
+
+CREATE INDEX idx_users_plan_code ON users(plan_code);
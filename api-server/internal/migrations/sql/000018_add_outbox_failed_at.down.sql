@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_messages_unpublished;
+CREATE INDEX idx_outbox_messages_unpublished ON outbox_messages(id) WHERE published_at IS NULL;
+
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS failed_at;
@@ -0,0 +1,3 @@
+-- A message whose publish failed is retried with backoff instead of on every
+-- relay tick; next_attempt_at is when the relay may try it again.
+ALTER TABLE outbox_messages ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE;
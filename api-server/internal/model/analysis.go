@@ -8,12 +8,25 @@ import (
 
 type AnalysisStatus string
 type AnalysisErrorCode string
+type AnalysisStage string
 
 const (
 	AnalysisStatusPending    AnalysisStatus = "pending"
 	AnalysisStatusProcessing AnalysisStatus = "processing"
 	AnalysisStatusCompleted  AnalysisStatus = "completed"
 	AnalysisStatusFailed     AnalysisStatus = "failed"
+	AnalysisStatusCancelled  AnalysisStatus = "cancelled"
+)
+
+// IsTerminal reports whether the analysis has reached a final state that no
+// later callback may change.
+func (s AnalysisStatus) IsTerminal() bool {
+	return s == AnalysisStatusCompleted || s == AnalysisStatusFailed || s == AnalysisStatusCancelled
+}
+
+const (
+	AnalysisStageAIDetection AnalysisStage = "ai_detection"
+	AnalysisStageLLMScoring  AnalysisStage = "llm_scoring"
 )
 
 const (
@@ -22,13 +35,24 @@ const (
 	AnalysisErrorCodeInvalidIn AnalysisErrorCode = "INVALID_INPUT"
 	AnalysisErrorCodeTimeout   AnalysisErrorCode = "TIMEOUT"
 	AnalysisErrorCodeInternal  AnalysisErrorCode = "INTERNAL_ERROR"
+	AnalysisErrorCodeExhausted AnalysisErrorCode = "RETRIES_EXHAUSTED"
+	// AnalysisErrorCodeUndeliverable marks analyses whose task the outbox
+	// relay could not hand to the message queue.
+	AnalysisErrorCodeUndeliverable AnalysisErrorCode = "UNDELIVERABLE"
+	// AnalysisErrorCodeUnsupportedSchema is reported by ML workers for tasks
+	// in a schema version they do not support.
+	AnalysisErrorCodeUnsupportedSchema AnalysisErrorCode = "UNSUPPORTED_SCHEMA"
 )
 
 type Analysis struct {
 	ID           uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	WritingID    uuid.UUID          `gorm:"type:uuid;not null;index" json:"writing_id"`
 	TaskID       *uuid.UUID         `gorm:"type:uuid;uniqueIndex" json:"task_id"`
+	RevisionID   *uuid.UUID         `gorm:"type:uuid;index" json:"revision_id"`
+	ContentHash  *string            `gorm:"type:varchar(64)" json:"content_hash"`
 	Status       AnalysisStatus     `gorm:"type:varchar(50);not null;default:'pending'" json:"status"`
+	Stage        *AnalysisStage     `gorm:"type:varchar(50)" json:"stage"`
+	Progress     *int               `gorm:"type:integer" json:"progress"`
 	AIScore      *float64           `gorm:"type:decimal(5,2)" json:"ai_score"`
 	Feedback     *string            `gorm:"type:text" json:"feedback"`
 	ErrorCode    *AnalysisErrorCode `gorm:"type:varchar(50)" json:"error_code"`
@@ -39,25 +63,49 @@ type Analysis struct {
 	UpdatedAt    time.Time          `gorm:"not null;default:now()" json:"updated_at"`
 
 	// Relations
-	Writing Writing `gorm:"foreignKey:WritingID" json:"-"`
+	Writing     Writing        `gorm:"foreignKey:WritingID" json:"-"`
+	Score       *AnalysisScore `gorm:"foreignKey:AnalysisID" json:"score,omitempty"`
+	AIDetection *AIDetection   `gorm:"foreignKey:AnalysisID" json:"ai_detection,omitempty"`
 }
 
 func (Analysis) TableName() string {
 	return "analyses"
 }
 
+// AnalysisLog records one callback. The analyzed text is referenced through
+// RevisionID; InputText is only set for analyses that predate revisions.
 type AnalysisLog struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	AnalysisID   uuid.UUID `gorm:"type:uuid;not null;index" json:"analysis_id"`
-	InputText    string    `gorm:"type:text;not null" json:"input_text"`
-	ModelVersion string    `gorm:"type:varchar(50);not null" json:"model_version"`
-	RawOutput    *string   `gorm:"type:jsonb" json:"raw_output"`
-	CreatedAt    time.Time `gorm:"not null;default:now()" json:"created_at"`
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AnalysisID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"analysis_id"`
+	RevisionID   *uuid.UUID `gorm:"type:uuid" json:"revision_id"`
+	InputText    *string    `gorm:"type:text" json:"input_text"`
+	ModelVersion string     `gorm:"type:varchar(50);not null" json:"model_version"`
+	RawOutput    *string    `gorm:"type:jsonb" json:"raw_output"`
+	CreatedAt    time.Time  `gorm:"not null;default:now()" json:"created_at"`
 
 	// Relations
-	Analysis Analysis `gorm:"foreignKey:AnalysisID" json:"-"`
+	Analysis Analysis         `gorm:"foreignKey:AnalysisID" json:"-"`
+	Revision *WritingRevision `gorm:"foreignKey:RevisionID" json:"-"`
 }
 
 func (AnalysisLog) TableName() string {
 	return "analysis_logs"
 }
+
+type AnalysisAttempt struct {
+	ID           uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AnalysisID   uuid.UUID          `gorm:"type:uuid;not null;index" json:"analysis_id"`
+	Attempt      int                `gorm:"not null" json:"attempt"`
+	ErrorCode    *AnalysisErrorCode `gorm:"type:varchar(50)" json:"error_code"`
+	ErrorMessage *string            `gorm:"type:text" json:"error_message"`
+	Retryable    bool               `gorm:"not null;default:false" json:"retryable"`
+	NextRetryAt  *time.Time         `json:"next_retry_at"`
+	CreatedAt    time.Time          `gorm:"not null;default:now()" json:"created_at"`
+
+	// Relations
+	Analysis Analysis `gorm:"foreignKey:AnalysisID" json:"-"`
+}
+
+func (AnalysisAttempt) TableName() string {
+	return "analysis_attempts"
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AIDetection struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AnalysisID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"analysis_id"`
+	Score        float64   `gorm:"type:decimal(5,4);not null" json:"score"`
+	IsFlagged    bool      `gorm:"not null;default:false" json:"is_flagged"`
+	Threshold    float64   `gorm:"type:decimal(5,4);not null" json:"threshold"`
+	ModelVersion string    `gorm:"type:varchar(50);not null" json:"model_version"`
+	DetectedAt   time.Time `gorm:"not null" json:"detected_at"`
+	CreatedAt    time.Time `gorm:"not null;default:now()" json:"created_at"`
+}
+
+func (AIDetection) TableName() string {
+	return "ai_detections"
+}
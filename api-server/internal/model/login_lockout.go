@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginLockout records an account or client IP being locked out after too
+// many failed logins. For IP lockouts Email is the address of the attempt
+// that tripped the lock.
+type LoginLockout struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Scope       string    `gorm:"type:varchar(20);not null" json:"scope"`
+	Email       *string   `gorm:"type:varchar(255);index" json:"email"`
+	IPAddress   string    `gorm:"type:varchar(45);not null;index" json:"ip_address"`
+	Failures    int       `gorm:"not null" json:"failures"`
+	LockedUntil time.Time `gorm:"not null" json:"locked_until"`
+	CreatedAt   time.Time `gorm:"not null;default:now()" json:"created_at"`
+}
+
+func (LoginLockout) TableName() string {
+	return "login_lockouts"
+}
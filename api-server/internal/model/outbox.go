@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+type OutboxKind string
+
+const (
+	OutboxKindAnalysisTask   OutboxKind = "analysis_task"
+	OutboxKindAnalysisCancel OutboxKind = "analysis_cancel"
+	OutboxKindAnalysisRetry  OutboxKind = "analysis_retry"
+)
+
+type OutboxMessage struct {
+	ID            int64      `gorm:"primary_key;autoIncrement" json:"id"`
+	Kind          OutboxKind `gorm:"type:varchar(50);not null" json:"kind"`
+	Payload       string     `gorm:"type:jsonb;not null" json:"payload"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     *string    `gorm:"type:text" json:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time  `gorm:"not null;default:now()" json:"created_at"`
+	PublishedAt   *time.Time `json:"published_at"`
+	FailedAt      *time.Time `json:"failed_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+type Plan struct {
+	Code                   string     `gorm:"type:varchar(50);primary_key" json:"code"`
+	Name                   string     `gorm:"type:varchar(100);not null" json:"name"`
+	DailySubmissionLimit   int        `gorm:"not null" json:"daily_submission_limit"`
+	MonthlySubmissionLimit int        `gorm:"not null" json:"monthly_submission_limit"`
+	MaxContentLength       int        `gorm:"not null" json:"max_content_length"`
+	Features               StringList `gorm:"type:jsonb;not null;default:'[]'" json:"features"`
+	CreatedAt              time.Time  `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt              time.Time  `gorm:"not null;default:now()" json:"updated_at"`
+}
+
+func (Plan) TableName() string {
+	return "plans"
+}
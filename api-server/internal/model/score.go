@@ -0,0 +1,61 @@
+package model
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RubricCriterion string
+
+const (
+	RubricCriterionContent   RubricCriterion = "content"
+	RubricCriterionStructure RubricCriterion = "structure"
+	RubricCriterionLanguage  RubricCriterion = "language"
+)
+
+// Maximum points per criterion of the TOPIK II question 54 rubric.
+const (
+	MaxContentScore   = 20
+	MaxStructureScore = 15
+	MaxLanguageScore  = 15
+	MaxTotalScore     = MaxContentScore + MaxStructureScore + MaxLanguageScore
+)
+
+type Deduction struct {
+	Criterion RubricCriterion `json:"criterion"`
+	Reason    string          `json:"reason"`
+}
+
+// Deductions is a list of deduction reasons stored as a JSONB array.
+type Deductions []Deduction
+
+func (d Deductions) Value() (driver.Value, error) {
+	return marshalJSONB(d, "[]")
+}
+
+func (d *Deductions) Scan(value interface{}) error {
+	return unmarshalJSONB(value, d)
+}
+
+type AnalysisScore struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AnalysisID        uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"analysis_id"`
+	SchemaVersion     string     `gorm:"type:varchar(20);not null" json:"schema_version"`
+	ContentScore      int        `gorm:"not null" json:"content_score"`
+	StructureScore    int        `gorm:"not null" json:"structure_score"`
+	LanguageScore     int        `gorm:"not null" json:"language_score"`
+	TotalScore        int        `gorm:"not null" json:"total_score"`
+	ContentFeedback   *string    `gorm:"type:text" json:"content_feedback"`
+	StructureFeedback *string    `gorm:"type:text" json:"structure_feedback"`
+	LanguageFeedback  *string    `gorm:"type:text" json:"language_feedback"`
+	Deductions        Deductions `gorm:"type:jsonb;not null;default:'[]'" json:"deductions"`
+	Suggestions       StringList `gorm:"type:jsonb;not null;default:'[]'" json:"suggestions"`
+	LevelEstimate     *string    `gorm:"type:varchar(10)" json:"level_estimate"`
+	CreatedAt         time.Time  `gorm:"not null;default:now()" json:"created_at"`
+}
+
+func (AnalysisScore) TableName() string {
+	return "analysis_scores"
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	UserAgent     *string    `gorm:"type:text" json:"user_agent"`
+	IPAddress     *string    `gorm:"type:varchar(45)" json:"ip_address"`
+	CreatedAt     time.Time  `gorm:"not null;default:now()" json:"created_at"`
+	LastSeenAt    time.Time  `gorm:"not null;default:now()" json:"last_seen_at"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason *string    `gorm:"type:varchar(50)" json:"revoked_reason"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+type RefreshToken struct {
+	TokenHash string     `gorm:"type:varchar(64);primary_key" json:"-"`
+	SessionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	CreatedAt time.Time  `gorm:"not null;default:now()" json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
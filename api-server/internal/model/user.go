@@ -7,13 +7,17 @@ import (
 )
 
 type User struct {
-	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Email            string     `gorm:"uniqueIndex;not null;size:255" json:"email"`
-	PasswordHash     string     `gorm:"not null;size:255" json:"-"`
-	DailySubmitCount int        `gorm:"not null;default:0" json:"daily_submit_count"`
-	LastSubmitDate   *time.Time `gorm:"type:date" json:"last_submit_date"`
-	CreatedAt        time.Time  `gorm:"not null;default:now()" json:"created_at"`
-	UpdatedAt        time.Time  `gorm:"not null;default:now()" json:"updated_at"`
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Email              string     `gorm:"uniqueIndex;not null;size:255" json:"email"`
+	PasswordHash       string     `gorm:"not null;size:255" json:"-"`
+	Role               string     `gorm:"type:varchar(20);not null;default:'user'" json:"role"`
+	Timezone           string     `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone"`
+	PlanCode           string     `gorm:"type:varchar(50);not null;default:'free'" json:"plan_code"`
+	DailySubmitCount   int        `gorm:"not null;default:0" json:"daily_submit_count"`
+	MonthlySubmitCount int        `gorm:"not null;default:0" json:"monthly_submit_count"`
+	LastSubmitDate     *time.Time `gorm:"type:date" json:"last_submit_date"`
+	CreatedAt          time.Time  `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"not null;default:now()" json:"updated_at"`
 }
 
 func (User) TableName() string {
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type WritingType string
@@ -21,17 +22,32 @@ const (
 )
 
 type Writing struct {
-	ID          uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID      uuid.UUID     `gorm:"type:uuid;not null;index" json:"user_id"`
-	Type        WritingType   `gorm:"type:varchar(50);not null" json:"type"`
-	Title       string        `gorm:"type:varchar(255);not null" json:"title"`
-	Content     string        `gorm:"type:text;not null" json:"content"`
-	Status      WritingStatus `gorm:"type:varchar(50);not null;default:'draft'" json:"status"`
-	CreatedAt   time.Time     `gorm:"not null;default:now()" json:"created_at"`
-	UpdatedAt   time.Time     `gorm:"not null;default:now()" json:"updated_at"`
-	SubmittedAt *time.Time    `json:"submitted_at"`
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type        WritingType    `gorm:"type:varchar(50);not null" json:"type"`
+	Title       string         `gorm:"type:varchar(255);not null" json:"title"`
+	Content     string         `gorm:"type:text;not null" json:"content"`
+	Status      WritingStatus  `gorm:"type:varchar(50);not null;default:'draft'" json:"status"`
+	CreatedAt   time.Time      `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"not null;default:now()" json:"updated_at"`
+	SubmittedAt *time.Time     `json:"submitted_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (Writing) TableName() string {
 	return "writings"
 }
+
+type WritingRevision struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WritingID      uuid.UUID   `gorm:"type:uuid;not null;index" json:"writing_id"`
+	RevisionNumber int         `gorm:"not null" json:"revision_number"`
+	Type           WritingType `gorm:"type:varchar(50);not null" json:"type"`
+	Title          string      `gorm:"type:varchar(255);not null" json:"title"`
+	Content        string      `gorm:"type:text;not null" json:"content"`
+	CreatedAt      time.Time   `gorm:"not null;default:now()" json:"created_at"`
+}
+
+func (WritingRevision) TableName() string {
+	return "writing_revisions"
+}
@@ -0,0 +1,163 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const channelBufferSize = 1024
+
+// ErrClosed is returned by publishers that have been closed.
+var ErrClosed = errors.New("mq: publisher closed")
+
+func init() {
+	Register("channel", func(Options) (Publisher, error) {
+		return NewChannelPublisher(channelBufferSize), nil
+	})
+}
+
+type delayedTask struct {
+	task AnalysisTask
+	at   time.Time
+}
+
+// ChannelPublisher delivers tasks over in-process channels. It keeps nothing
+// across restarts and is meant for tests and for running the ML worker inside
+// the same binary during development.
+type ChannelPublisher struct {
+	tasks   chan AnalysisTask
+	cancels chan CancelTask
+	done    chan struct{}
+
+	mu      sync.Mutex
+	delayed []delayedTask
+	closed  bool
+}
+
+func NewChannelPublisher(buffer int) *ChannelPublisher {
+	return &ChannelPublisher{
+		tasks:   make(chan AnalysisTask, buffer),
+		cancels: make(chan CancelTask, buffer),
+		done:    make(chan struct{}),
+	}
+}
+
+// Tasks delivers published tasks, and delayed tasks once PromoteDue has
+// released them.
+func (p *ChannelPublisher) Tasks() <-chan AnalysisTask {
+	return p.tasks
+}
+
+func (p *ChannelPublisher) Cancels() <-chan CancelTask {
+	return p.cancels
+}
+
+// Publish blocks while the buffer is full, until ctx is done.
+func (p *ChannelPublisher) Publish(ctx context.Context, task AnalysisTask) error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-p.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ChannelPublisher) PublishAt(ctx context.Context, task AnalysisTask, at time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+	p.delayed = append(p.delayed, delayedTask{task: task, at: at})
+	return nil
+}
+
+// PublishCancel also drops the task's parked retries so that they are never
+// delivered.
+func (p *ChannelPublisher) PublishCancel(ctx context.Context, cancel CancelTask) error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+
+	p.mu.Lock()
+	pending := p.delayed[:0]
+	for _, d := range p.delayed {
+		if d.task.TaskID != cancel.TaskID {
+			pending = append(pending, d)
+		}
+	}
+	p.delayed = pending
+	p.mu.Unlock()
+
+	select {
+	case p.cancels <- cancel:
+		return nil
+	case <-p.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ChannelPublisher) PromoteDue(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	now := time.Now()
+	var due []AnalysisTask
+	pending := p.delayed[:0]
+	for _, d := range p.delayed {
+		if d.at.After(now) {
+			pending = append(pending, d)
+			continue
+		}
+		due = append(due, d.task)
+	}
+	p.delayed = pending
+	p.mu.Unlock()
+
+	for i, task := range due {
+		if err := p.Publish(ctx, task); err != nil {
+			// Park whatever could not be delivered so the next sweep
+			// picks it up again.
+			p.mu.Lock()
+			for _, rest := range due[i:] {
+				p.delayed = append(p.delayed, delayedTask{task: rest, at: now})
+			}
+			p.mu.Unlock()
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+func (p *ChannelPublisher) Health(ctx context.Context) error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+func (p *ChannelPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+	return nil
+}
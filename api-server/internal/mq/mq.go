@@ -2,27 +2,136 @@ package mq
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/schema"
 )
 
-type WritingType string
+type WritingType = schema.WritingType
 
 const (
-	WritingTypeEssay       WritingType = "essay"
-	WritingTypeCoverLetter WritingType = "cover_letter"
+	WritingTypeEssay       = schema.WritingTypeEssay
+	WritingTypeCoverLetter = schema.WritingTypeCoverLetter
 )
 
-type AnalysisTask struct {
-	Version     string      `json:"version"`
-	TaskID      uuid.UUID   `json:"task_id"`
-	WritingID   uuid.UUID   `json:"writing_id"`
-	Content     string      `json:"content"`
-	WritingType WritingType `json:"writing_type"`
-	CallbackURL string      `json:"callback_url"`
+// AnalysisTask is encoded in the schema version it names, so every backend
+// publishes the wire format the workers negotiated.
+type AnalysisTask = schema.Task
+
+// ScheduledTask is a task to be published once At has passed.
+type ScheduledTask struct {
+	Task AnalysisTask `json:"task"`
+	At   time.Time    `json:"at"`
+}
+
+// CancelRetention is how long cancels are kept on the control stream. Every
+// worker reads the whole stream, and a cancel only matters while its task can
+// still be in flight, so older cancels are trimmed as new ones are published.
+const CancelRetention = time.Hour
+
+// CancelTask asks the workers to drop a task. It travels on a control
+// stream separate from the tasks themselves, so workers can honor it even
+// while the task stream is backed up.
+type CancelTask struct {
+	TaskID      uuid.UUID `json:"task_id"`
+	WritingID   uuid.UUID `json:"writing_id"`
+	RequestedAt time.Time `json:"requested_at"`
 }
 
 type Publisher interface {
 	Publish(ctx context.Context, task AnalysisTask) error
+	// PublishAt holds the task back until at, after which it is delivered
+	// exactly like a task passed to Publish.
+	PublishAt(ctx context.Context, task AnalysisTask, at time.Time) error
+	PublishCancel(ctx context.Context, cancel CancelTask) error
+	// Health reports whether the backend can currently accept messages.
+	Health(ctx context.Context) error
 	Close() error
 }
+
+// Scheduler is implemented by publishers that park delayed tasks and need
+// a periodic sweep to release the ones that have come due.
+type Scheduler interface {
+	PromoteDue(ctx context.Context) (int, error)
+}
+
+// PendingTask is a task that a consumer has read from the stream but not yet
+// acknowledged.
+type PendingTask struct {
+	MessageID string
+	TaskID    uuid.UUID
+	Consumer  string
+	Idle      time.Duration
+	// Deliveries counts how often this message was handed to a consumer.
+	Deliveries int64
+	// Attempt is the attempt the task carries; a re-queued task goes out as
+	// a new message with the next attempt.
+	Attempt int
+	// Payload is the message as stored on the stream. It is empty if the
+	// message was trimmed away before it was acknowledged.
+	Payload []byte
+	// Malformed is set when Payload does not decode as an AnalysisTask, in
+	// which case TaskID is uuid.Nil.
+	Malformed bool
+}
+
+// TaskDeliveries is how often the task was handed to a consumer across all
+// its attempts, counting each earlier attempt as one delivery.
+func (t PendingTask) TaskDeliveries() int64 {
+	if t.Attempt <= 1 {
+		return t.Deliveries
+	}
+	return int64(t.Attempt-1) + t.Deliveries
+}
+
+// Inspector exposes the delivery state of tasks held by a consumer group.
+type Inspector interface {
+	PendingTasks(ctx context.Context, group string) ([]PendingTask, error)
+	Ack(ctx context.Context, group string, messageIDs ...string) error
+}
+
+// Reasons a task is dead-lettered.
+const (
+	DeadLetterReasonMaxDeliveries = "max_deliveries"
+	DeadLetterReasonMalformed     = "malformed"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("mq: dead letter not found")
+	// ErrNotReplayable is returned for dead letters without a task payload,
+	// such as messages that were trimmed before they could be moved.
+	ErrNotReplayable = errors.New("mq: dead letter has no task to replay")
+)
+
+// DeadLetter is a task taken off the task stream because it could not be
+// delivered.
+type DeadLetter struct {
+	ID         string
+	MessageID  string
+	TaskID     uuid.UUID
+	Payload    []byte
+	Reason     string
+	Deliveries int64
+	DeadAt     time.Time
+}
+
+// DeadLetterQueue is implemented by backends that can set undeliverable
+// tasks aside for an operator to inspect, replay or purge.
+type DeadLetterQueue interface {
+	// DeadLetter moves a pending task to the dead-letter queue and
+	// acknowledges it on behalf of group.
+	DeadLetter(ctx context.Context, group string, task PendingTask, reason string) error
+	// DeadLetters lists up to limit dead letters, newest first, starting
+	// after the dead letter with ID before when it is not empty.
+	DeadLetters(ctx context.Context, before string, limit int64) ([]DeadLetter, int64, error)
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+	// ReplayDeadLetter puts the task back on the task stream and drops the
+	// dead letter.
+	ReplayDeadLetter(ctx context.Context, id string) error
+	// PurgeDeadLetters drops the given dead letters, or all of them when no
+	// IDs are given, and reports how many were dropped.
+	PurgeDeadLetters(ctx context.Context, ids ...string) (int64, error)
+}
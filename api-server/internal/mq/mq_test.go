@@ -0,0 +1,250 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/truegul/api-server/internal/migrations"
+	"github.com/truegul/api-server/internal/schema"
+)
+
+func testTask() AnalysisTask {
+	return AnalysisTask{
+		Version:     schema.V1,
+		TaskID:      uuid.New(),
+		WritingID:   uuid.New(),
+		Content:     "테스트 글입니다.",
+		WritingType: WritingTypeEssay,
+		CallbackURL: "http://localhost:8080/api/v1/internal/callback",
+		Attempt:     1,
+	}
+}
+
+// decodeTask reads a task back the way a worker would, from the JSON that
+// was put on the queue.
+func decodeTask(t *testing.T, payload []byte) AnalysisTask {
+	t.Helper()
+
+	var task AnalysisTask
+	if err := json.Unmarshal(payload, &task); err != nil {
+		t.Fatalf("decode task %s: %v", payload, err)
+	}
+	return task
+}
+
+func assertSameTask(t *testing.T, got, want AnalysisTask) {
+	t.Helper()
+
+	if got.TaskID != want.TaskID || got.WritingID != want.WritingID || got.Content != want.Content || got.WritingType != want.WritingType {
+		t.Fatalf("round-tripped task = %+v, want %+v", got, want)
+	}
+}
+
+func TestChannelRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	publisher := NewChannelPublisher(4)
+	defer publisher.Close()
+
+	task := testTask()
+	if err := publisher.Publish(ctx, task); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	select {
+	case got := <-publisher.Tasks():
+		assertSameTask(t, got, task)
+	default:
+		t.Fatal("published task was not delivered")
+	}
+
+	delayed := testTask()
+	if err := publisher.PublishAt(ctx, delayed, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("publish at: %v", err)
+	}
+	if promoted, err := publisher.PromoteDue(ctx); err != nil || promoted != 1 {
+		t.Fatalf("promote due = %d, %v; want 1, nil", promoted, err)
+	}
+	assertSameTask(t, <-publisher.Tasks(), delayed)
+}
+
+func TestChannelCancelDropsParkedRetry(t *testing.T) {
+	ctx := context.Background()
+	publisher := NewChannelPublisher(4)
+	defer publisher.Close()
+
+	task := testTask()
+	if err := publisher.PublishAt(ctx, task, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("publish at: %v", err)
+	}
+	if err := publisher.PublishCancel(ctx, CancelTask{TaskID: task.TaskID, WritingID: task.WritingID, RequestedAt: time.Now()}); err != nil {
+		t.Fatalf("publish cancel: %v", err)
+	}
+	if promoted, err := publisher.PromoteDue(ctx); err != nil || promoted != 0 {
+		t.Fatalf("promote due = %d, %v; want 0, nil", promoted, err)
+	}
+}
+
+// testDB connects to the database named by TEST_DATABASE_URL and brings its
+// schema up to date. Tests that need it are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	source, err := iofs.New(migrations.FS, migrations.Dir)
+	if err != nil {
+		t.Fatalf("open migrations: %v", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return db
+}
+
+func TestPostgresRoundTrip(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	queue := "test_tasks_" + uuid.NewString()
+	publisher, err := Open("postgres", Options{DB: db, StreamName: queue, ControlStreamName: queue + "_control"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	pg := publisher.(*PostgresPublisher)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM mq_messages WHERE queue IN ?", []string{queue, queue + "_control"})
+	})
+
+	task := testTask()
+	if err := publisher.Publish(ctx, task); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	messages, err := pg.Claim(ctx, queue, "test-worker", 10)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("claimed %d messages, want 1", len(messages))
+	}
+	assertSameTask(t, decodeTask(t, messages[0].Payload), task)
+
+	pending, err := pg.PendingTasks(ctx, "")
+	if err != nil || len(pending) != 1 || pending[0].TaskID != task.TaskID {
+		t.Fatalf("pending = %+v, %v; want the claimed task", pending, err)
+	}
+
+	if err := pg.Ack(ctx, "", messages[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if pending, err := pg.PendingTasks(ctx, ""); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after ack = %+v, %v; want none", pending, err)
+	}
+}
+
+func TestPostgresCancelTrimsOldCancels(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	queue := "test_tasks_" + uuid.NewString()
+	control := queue + "_control"
+	publisher := NewPostgresPublisher(db, queue, control)
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM mq_messages WHERE queue IN ?", []string{queue, control})
+	})
+
+	old := CancelTask{TaskID: uuid.New(), WritingID: uuid.New(), RequestedAt: time.Now()}
+	if err := publisher.enqueue(ctx, db, control, old, time.Now().Add(-2*CancelRetention)); err != nil {
+		t.Fatalf("seed old cancel: %v", err)
+	}
+
+	task := testTask()
+	if err := publisher.PublishCancel(ctx, CancelTask{TaskID: task.TaskID, WritingID: task.WritingID, RequestedAt: time.Now()}); err != nil {
+		t.Fatalf("publish cancel: %v", err)
+	}
+
+	var taskIDs []string
+	if err := db.Raw("SELECT payload->>'task_id' FROM mq_messages WHERE queue = ?", control).Scan(&taskIDs).Error; err != nil {
+		t.Fatalf("list cancels: %v", err)
+	}
+	if len(taskIDs) != 1 || taskIDs[0] != task.TaskID.String() {
+		t.Fatalf("control queue holds %v, want only the new cancel", taskIDs)
+	}
+}
+
+func TestRedisRoundTrip(t *testing.T) {
+	redisURL := os.Getenv("TEST_REDIS_URL")
+	if redisURL == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+
+	ctx := context.Background()
+	stream := "test_tasks_" + uuid.NewString()
+	group := "test_workers"
+	publisher, err := Open("redis", Options{RedisURL: redisURL, StreamName: stream, ControlStreamName: stream + "_control"})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	rp := publisher.(*RedisPublisher)
+	client := rp.Client()
+	t.Cleanup(func() {
+		client.Del(context.Background(), stream, stream+"_control", rp.delayedKey(), rp.deadLetterKey())
+		publisher.Close()
+	})
+
+	if err := client.XGroupCreateMkStream(ctx, stream, group, "0").Err(); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	task := testTask()
+	if err := publisher.Publish(ctx, task); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: "test-worker",
+		Streams:  []string{stream, ">"},
+		Count:    10,
+	}).Result()
+	if err != nil {
+		t.Fatalf("read group: %v", err)
+	}
+	if len(streams) != 1 || len(streams[0].Messages) != 1 {
+		t.Fatalf("read %+v, want one message", streams)
+	}
+	message := streams[0].Messages[0]
+	raw, _ := message.Values["task"].(string)
+	assertSameTask(t, decodeTask(t, []byte(raw)), task)
+
+	if err := rp.Ack(ctx, group, message.ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if pending, err := rp.PendingTasks(ctx, group); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after ack = %+v, %v; want none", pending, err)
+	}
+}
@@ -0,0 +1,199 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register("postgres", func(opts Options) (Publisher, error) {
+		if opts.DB == nil {
+			return nil, errors.New("mq: postgres backend needs a database connection")
+		}
+		return NewPostgresPublisher(opts.DB, opts.StreamName, opts.ControlStreamName), nil
+	})
+}
+
+// QueueMessage is a message a consumer has claimed from a Postgres queue.
+type QueueMessage struct {
+	ID         string
+	Payload    []byte
+	Deliveries int64
+}
+
+// PostgresPublisher keeps queues in the mq_messages table for deployments
+// without Redis. Delayed tasks simply carry a later available_at, so no
+// promotion sweep is needed. Consumers claim messages with Claim, which locks
+// with SKIP LOCKED so that concurrent consumers never claim the same row, and
+// delete them with Ack once handled.
+//
+// The table holds a single consumer group per queue; the group arguments of
+// the Inspector methods are accepted for compatibility and ignored.
+type PostgresPublisher struct {
+	db                *gorm.DB
+	streamName        string
+	controlStreamName string
+}
+
+func NewPostgresPublisher(db *gorm.DB, streamName, controlStreamName string) *PostgresPublisher {
+	return &PostgresPublisher{
+		db:                db,
+		streamName:        streamName,
+		controlStreamName: controlStreamName,
+	}
+}
+
+func (p *PostgresPublisher) Publish(ctx context.Context, task AnalysisTask) error {
+	return p.enqueue(ctx, p.db, p.streamName, task, time.Now())
+}
+
+func (p *PostgresPublisher) PublishAt(ctx context.Context, task AnalysisTask, at time.Time) error {
+	return p.enqueue(ctx, p.db, p.streamName, task, at)
+}
+
+// PublishCancel also deletes the task's unclaimed messages, parked retries
+// included, so that no consumer picks them up after the cancel. Workers read
+// the control queue without claiming it, so cancels older than
+// CancelRetention are deleted here as well.
+func (p *PostgresPublisher) PublishCancel(ctx context.Context, cancel CancelTask) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(
+			"DELETE FROM mq_messages WHERE queue = ? AND claimed_at IS NULL AND payload->>'task_id' = ?",
+			p.streamName, cancel.TaskID.String(),
+		).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(
+			"DELETE FROM mq_messages WHERE queue = ? AND claimed_at IS NULL AND available_at < ?",
+			p.controlStreamName, time.Now().Add(-CancelRetention),
+		).Error; err != nil {
+			return err
+		}
+		return p.enqueue(ctx, tx, p.controlStreamName, cancel, time.Now())
+	})
+}
+
+func (p *PostgresPublisher) enqueue(ctx context.Context, db *gorm.DB, queue string, payload interface{}, availableAt time.Time) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Exec(
+		"INSERT INTO mq_messages (queue, payload, available_at) VALUES (?, ?, ?)",
+		queue, string(payloadJSON), availableAt,
+	).Error
+}
+
+// Claim hands up to limit available messages on queue to consumer. Claimed
+// messages stay invisible to other consumers until acknowledged.
+func (p *PostgresPublisher) Claim(ctx context.Context, queue, consumer string, limit int) ([]QueueMessage, error) {
+	var rows []struct {
+		ID         int64
+		Payload    string
+		Deliveries int64
+	}
+	err := p.db.WithContext(ctx).Raw(`
+		UPDATE mq_messages
+		SET claimed_by = ?, claimed_at = NOW(), deliveries = deliveries + 1
+		WHERE id IN (
+			SELECT id FROM mq_messages
+			WHERE queue = ? AND claimed_at IS NULL AND available_at <= NOW()
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload, deliveries`,
+		consumer, queue, limit,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]QueueMessage, len(rows))
+	for i, row := range rows {
+		messages[i] = QueueMessage{
+			ID:         strconv.FormatInt(row.ID, 10),
+			Payload:    []byte(row.Payload),
+			Deliveries: row.Deliveries,
+		}
+	}
+	return messages, nil
+}
+
+func (p *PostgresPublisher) PendingTasks(ctx context.Context, group string) ([]PendingTask, error) {
+	var rows []struct {
+		ID         int64
+		Payload    string
+		ClaimedBy  string
+		ClaimedAt  time.Time
+		Deliveries int64
+	}
+	err := p.db.WithContext(ctx).Raw(`
+		SELECT id, payload, claimed_by, claimed_at, deliveries
+		FROM mq_messages
+		WHERE queue = ? AND claimed_at IS NOT NULL
+		ORDER BY id
+		LIMIT ?`,
+		p.streamName, pendingScanLimit,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]PendingTask, 0, len(rows))
+	for _, row := range rows {
+		pending := PendingTask{
+			MessageID:  strconv.FormatInt(row.ID, 10),
+			Consumer:   row.ClaimedBy,
+			Idle:       time.Since(row.ClaimedAt),
+			Deliveries: row.Deliveries,
+			Payload:    []byte(row.Payload),
+		}
+
+		var task AnalysisTask
+		if err := json.Unmarshal(pending.Payload, &task); err != nil {
+			pending.Malformed = true
+		} else {
+			pending.TaskID = task.TaskID
+			pending.Attempt = task.Attempt
+		}
+
+		tasks = append(tasks, pending)
+	}
+	return tasks, nil
+}
+
+func (p *PostgresPublisher) Ack(ctx context.Context, group string, messageIDs ...string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		id, err := strconv.ParseInt(messageID, 10, 64)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	return p.db.WithContext(ctx).Exec("DELETE FROM mq_messages WHERE id IN ?", ids).Error
+}
+
+func (p *PostgresPublisher) Health(ctx context.Context) error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close leaves the database connection open; it belongs to the caller.
+func (p *PostgresPublisher) Close() error {
+	return nil
+}
@@ -3,16 +3,94 @@ package mq
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	promoteBatchSize = 100
+	pendingScanLimit = 1000
+)
+
+// promoteScript moves due members of the delayed sorted set onto the stream
+// in one atomic step so that concurrent api-server replicas never deliver the
+// same delayed task twice. ARGV[3] is the stream's approximate MAXLEN, or 0
+// to leave it untrimmed.
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, task in ipairs(due) do
+	if tonumber(ARGV[3]) > 0 then
+		redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[3], '*', 'task', task)
+	else
+		redis.call('XADD', KEYS[2], '*', 'task', task)
+	end
+	redis.call('ZREM', KEYS[1], task)
+end
+return #due
+`)
+
+// replayScript moves a dead letter back onto the task stream atomically, so
+// that a dead letter is never replayed twice. It returns 0 when the dead
+// letter does not exist and -1 when it carries no task.
+var replayScript = redis.NewScript(`
+local entries = redis.call('XRANGE', KEYS[1], ARGV[1], ARGV[1])
+if #entries == 0 then
+	return 0
+end
+local fields = entries[1][2]
+local task
+for i = 1, #fields, 2 do
+	if fields[i] == 'task' then
+		task = fields[i + 1]
+	end
+end
+if not task or task == '' then
+	return -1
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], '*', 'task', task)
+else
+	redis.call('XADD', KEYS[2], '*', 'task', task)
+end
+redis.call('XDEL', KEYS[1], ARGV[1])
+return 1
+`)
+
+// unscheduleScript removes the delayed members carrying task ARGV[1], so a
+// cancelled analysis's parked retry is never promoted. The delayed set only
+// holds retries waiting out their backoff, so scanning it is cheap.
+var unscheduleScript = redis.NewScript(`
+local removed = 0
+for _, task in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+	local ok, decoded = pcall(cjson.decode, task)
+	if ok and type(decoded) == 'table' and decoded['task_id'] == ARGV[1] then
+		removed = removed + redis.call('ZREM', KEYS[1], task)
+	end
+end
+return removed
+`)
+
+func init() {
+	Register("redis", func(opts Options) (Publisher, error) {
+		return NewRedisPublisher(opts.RedisURL, opts.StreamName, opts.ControlStreamName, opts.StreamMaxLen)
+	})
+}
+
+// RedisPublisher publishes tasks on Redis Streams. The task stream is trimmed
+// to roughly maxLen entries, zero leaving it unbounded, and the control stream
+// by age. The dead-letter stream is never trimmed: its entries are kept until
+// an operator replays or purges them.
 type RedisPublisher struct {
-	client     *redis.Client
-	streamName string
+	client            *redis.Client
+	streamName        string
+	controlStreamName string
+	maxLen            int64
 }
 
-func NewRedisPublisher(redisURL, streamName string) (*RedisPublisher, error) {
+func NewRedisPublisher(redisURL, streamName, controlStreamName string, maxLen int64) (*RedisPublisher, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, err
@@ -25,8 +103,10 @@ func NewRedisPublisher(redisURL, streamName string) (*RedisPublisher, error) {
 	}
 
 	return &RedisPublisher{
-		client:     client,
-		streamName: streamName,
+		client:            client,
+		streamName:        streamName,
+		controlStreamName: controlStreamName,
+		maxLen:            maxLen,
 	}, nil
 }
 
@@ -36,14 +116,120 @@ func (p *RedisPublisher) Publish(ctx context.Context, task AnalysisTask) error {
 		return err
 	}
 
+	return p.client.XAdd(ctx, p.taskArgs(map[string]interface{}{
+		"task": string(taskJSON),
+	})).Err()
+}
+
+func (p *RedisPublisher) PublishAt(ctx context.Context, task AnalysisTask, at time.Time) error {
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	return p.client.ZAdd(ctx, p.delayedKey(), redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: string(taskJSON),
+	}).Err()
+}
+
+// PublishCancel drops the task's parked retries before telling the workers,
+// so a retry scheduled ahead of the cancel is not delivered afterwards.
+func (p *RedisPublisher) PublishCancel(ctx context.Context, cancel CancelTask) error {
+	cancelJSON, err := json.Marshal(cancel)
+	if err != nil {
+		return err
+	}
+
+	if err := unscheduleScript.Run(ctx, p.client, []string{p.delayedKey()}, cancel.TaskID.String()).Err(); err != nil {
+		return err
+	}
+
+	// The control stream is trimmed by age rather than length: stream IDs
+	// start with their Unix milliseconds, so this drops cancels older than
+	// CancelRetention.
+	minID := strconv.FormatInt(time.Now().Add(-CancelRetention).UnixMilli(), 10)
 	return p.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: p.streamName,
-		Values: map[string]interface{}{
-			"task": string(taskJSON),
-		},
+		Stream: p.controlStreamName,
+		MinID:  minID,
+		Approx: true,
+		Values: map[string]interface{}{"cancel": string(cancelJSON)},
 	}).Err()
 }
 
+func (p *RedisPublisher) PromoteDue(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return promoteScript.Run(ctx, p.client, []string{p.delayedKey(), p.streamName}, now, promoteBatchSize, p.maxLen).Int()
+}
+
+func (p *RedisPublisher) PendingTasks(ctx context.Context, group string) ([]PendingTask, error) {
+	entries, err := p.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: p.streamName,
+		Group:  group,
+		Start:  "-",
+		End:    "+",
+		Count:  pendingScanLimit,
+	}).Result()
+	if err != nil {
+		// The group only exists once a worker has connected.
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	pipe := p.client.Pipeline()
+	ranges := make([]*redis.XMessageSliceCmd, len(entries))
+	for i, entry := range entries {
+		ranges[i] = pipe.XRange(ctx, p.streamName, entry.ID, entry.ID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	tasks := make([]PendingTask, 0, len(entries))
+	for i, entry := range entries {
+		pending := PendingTask{
+			MessageID:  entry.ID,
+			Consumer:   entry.Consumer,
+			Idle:       entry.Idle,
+			Deliveries: entry.RetryCount,
+		}
+
+		// A message trimmed from the stream stays in the pending list with
+		// nothing left to decode.
+		if messages, err := ranges[i].Result(); err == nil && len(messages) > 0 {
+			raw, _ := messages[0].Values["task"].(string)
+			pending.Payload = []byte(raw)
+		}
+
+		var task AnalysisTask
+		if err := json.Unmarshal(pending.Payload, &task); err != nil {
+			pending.Malformed = true
+		} else {
+			pending.TaskID = task.TaskID
+			pending.Attempt = task.Attempt
+		}
+
+		tasks = append(tasks, pending)
+	}
+	return tasks, nil
+}
+
+func (p *RedisPublisher) Ack(ctx context.Context, group string, messageIDs ...string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return p.client.XAck(ctx, p.streamName, group, messageIDs...).Err()
+}
+
+func (p *RedisPublisher) Health(ctx context.Context) error {
+	return p.client.Ping(ctx).Err()
+}
+
 func (p *RedisPublisher) Close() error {
 	return p.client.Close()
 }
@@ -51,3 +237,133 @@ func (p *RedisPublisher) Close() error {
 func (p *RedisPublisher) Client() *redis.Client {
 	return p.client
 }
+
+func (p *RedisPublisher) DeadLetter(ctx context.Context, group string, task PendingTask, reason string) error {
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: p.deadLetterKey(),
+			Values: map[string]interface{}{
+				"task":       string(task.Payload),
+				"reason":     reason,
+				"message_id": task.MessageID,
+				"deliveries": task.TaskDeliveries(),
+				"dead_at":    time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		pipe.XAck(ctx, p.streamName, group, task.MessageID)
+		pipe.XDel(ctx, p.streamName, task.MessageID)
+		return nil
+	})
+	return err
+}
+
+func (p *RedisPublisher) DeadLetters(ctx context.Context, before string, limit int64) ([]DeadLetter, int64, error) {
+	end := "+"
+	if before != "" {
+		end = "(" + before
+	}
+
+	messages, err := p.client.XRevRangeN(ctx, p.deadLetterKey(), end, "-", limit).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := p.client.XLen(ctx, p.deadLetterKey()).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	letters := make([]DeadLetter, len(messages))
+	for i, message := range messages {
+		letters[i] = toDeadLetter(message)
+	}
+	return letters, total, nil
+}
+
+func (p *RedisPublisher) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	messages, err := p.client.XRange(ctx, p.deadLetterKey(), id, id).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	letter := toDeadLetter(messages[0])
+	return &letter, nil
+}
+
+func (p *RedisPublisher) ReplayDeadLetter(ctx context.Context, id string) error {
+	replayed, err := replayScript.Run(ctx, p.client, []string{p.deadLetterKey(), p.streamName}, id, p.maxLen).Int()
+	if err != nil {
+		return err
+	}
+
+	switch replayed {
+	case 0:
+		return ErrDeadLetterNotFound
+	case -1:
+		return ErrNotReplayable
+	}
+	return nil
+}
+
+func (p *RedisPublisher) PurgeDeadLetters(ctx context.Context, ids ...string) (int64, error) {
+	if len(ids) > 0 {
+		return p.client.XDel(ctx, p.deadLetterKey(), ids...).Result()
+	}
+
+	var purged *redis.IntCmd
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		purged = pipe.XLen(ctx, p.deadLetterKey())
+		pipe.Del(ctx, p.deadLetterKey())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged.Val(), nil
+}
+
+// taskArgs appends to the task stream, trimming it to roughly maxLen
+// entries. The approximate form lets Redis trim whole macro nodes, which is
+// far cheaper than trimming to an exact length.
+func (p *RedisPublisher) taskArgs(values map[string]interface{}) *redis.XAddArgs {
+	args := &redis.XAddArgs{
+		Stream: p.streamName,
+		Values: values,
+	}
+	if p.maxLen > 0 {
+		args.MaxLen = p.maxLen
+		args.Approx = true
+	}
+	return args
+}
+
+func (p *RedisPublisher) delayedKey() string {
+	return p.streamName + ":delayed"
+}
+
+func (p *RedisPublisher) deadLetterKey() string {
+	return p.streamName + ":dead"
+}
+
+func toDeadLetter(message redis.XMessage) DeadLetter {
+	letter := DeadLetter{ID: message.ID}
+
+	raw, _ := message.Values["task"].(string)
+	letter.Payload = []byte(raw)
+	var task AnalysisTask
+	if err := json.Unmarshal(letter.Payload, &task); err == nil {
+		letter.TaskID = task.TaskID
+	}
+
+	letter.Reason, _ = message.Values["reason"].(string)
+	letter.MessageID, _ = message.Values["message_id"].(string)
+	if deliveries, ok := message.Values["deliveries"].(string); ok {
+		letter.Deliveries, _ = strconv.ParseInt(deliveries, 10, 64)
+	}
+	if deadAt, ok := message.Values["dead_at"].(string); ok {
+		letter.DeadAt, _ = time.Parse(time.RFC3339, deadAt)
+	}
+	return letter
+}
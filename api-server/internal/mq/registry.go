@@ -0,0 +1,69 @@
+package mq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Options carries everything a backend might need to connect. Each backend
+// reads only the fields it uses.
+type Options struct {
+	RedisURL          string
+	DB                *gorm.DB
+	StreamName        string
+	ControlStreamName string
+	// StreamMaxLen caps the length of a stream-based task queue; zero leaves
+	// it unbounded. It does not apply to dead letters.
+	StreamMaxLen int64
+}
+
+// Factory opens a Publisher for one backend.
+type Factory func(opts Options) (Publisher, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Factory)
+)
+
+// Register makes a backend available to Open under name. Backends register
+// themselves from init; registering the same name twice panics.
+func Register(name string, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if factory == nil {
+		panic("mq: Register factory is nil")
+	}
+	if _, dup := backends[name]; dup {
+		panic("mq: Register called twice for backend " + name)
+	}
+	backends[name] = factory
+}
+
+// Open connects to the backend registered under name.
+func Open(name string, opts Options) (Publisher, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("mq: unknown backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	return factory(opts)
+}
+
+// Backends lists the registered backend names in sorted order.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,149 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ResultMessage is one ML result read from the results stream. Payload is
+// the JSON the ML server would otherwise have POSTed to the callback URL.
+// Deliveries counts how often the group has handed the result out,
+// including this time.
+type ResultMessage struct {
+	ID         string
+	Payload    []byte
+	Deliveries int64
+}
+
+// ResultReader reads ML results from a stream as one consumer of a consumer
+// group. Messages stay pending until acknowledged, so a result whose
+// processing fails is delivered again rather than lost.
+type ResultReader struct {
+	client    *redis.Client
+	stream    string
+	group     string
+	consumer  string
+	claimIdle time.Duration
+}
+
+func NewResultReader(client *redis.Client, stream, group, consumer string, claimIdle time.Duration) *ResultReader {
+	return &ResultReader{
+		client:    client,
+		stream:    stream,
+		group:     group,
+		consumer:  consumer,
+		claimIdle: claimIdle,
+	}
+}
+
+// EnsureGroup creates the consumer group, and the stream with it, unless it
+// already exists.
+func (r *ResultReader) EnsureGroup(ctx context.Context) error {
+	err := r.client.XGroupCreateMkStream(ctx, r.stream, r.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// Claim takes over results that have been pending for longer than the claim
+// idle time, whether left behind by this consumer after a failure or by a
+// replica that went away. Their delivery counts are read from the pending
+// list, which XAUTOCLAIM has already bumped for this delivery.
+func (r *ResultReader) Claim(ctx context.Context, count int64) ([]ResultMessage, error) {
+	claimed, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   r.stream,
+		Group:    r.group,
+		Consumer: r.consumer,
+		MinIdle:  r.claimIdle,
+		Start:    "0",
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages := toResultMessages(claimed)
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	pipe := r.client.Pipeline()
+	pending := make([]*redis.XPendingExtCmd, len(messages))
+	for i, message := range messages {
+		pending[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: r.stream,
+			Group:  r.group,
+			Start:  message.ID,
+			End:    message.ID,
+			Count:  1,
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		if entries := pending[i].Val(); len(entries) > 0 {
+			messages[i].Deliveries = entries[0].RetryCount
+		}
+	}
+	return messages, nil
+}
+
+// Read waits up to block for results no consumer in the group has seen yet.
+func (r *ResultReader) Read(ctx context.Context, count int64, block time.Duration) ([]ResultMessage, error) {
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.group,
+		Consumer: r.consumer,
+		Streams:  []string{r.stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var messages []ResultMessage
+	for _, stream := range streams {
+		for _, message := range toResultMessages(stream.Messages) {
+			message.Deliveries = 1
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// Ack acknowledges handled results and deletes them, which is what keeps
+// the results stream trimmed: it only ever holds results still waiting for
+// an api-server. The ML server adds results without MAXLEN so that no unread
+// result is trimmed away.
+func (r *ResultReader) Ack(ctx context.Context, messageIDs ...string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, r.stream, r.group, messageIDs...)
+		pipe.XDel(ctx, r.stream, messageIDs...)
+		return nil
+	})
+	return err
+}
+
+func toResultMessages(messages []redis.XMessage) []ResultMessage {
+	results := make([]ResultMessage, 0, len(messages))
+	for _, message := range messages {
+		payload, _ := message.Values["result"].(string)
+		results = append(results, ResultMessage{
+			ID:      message.ID,
+			Payload: []byte(payload),
+		})
+	}
+	return results
+}
@@ -0,0 +1,58 @@
+package mq
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestResultReaderCountsDeliveries(t *testing.T) {
+	redisURL := os.Getenv("TEST_REDIS_URL")
+	if redisURL == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	client := redis.NewClient(opt)
+	ctx := context.Background()
+	stream := "test_results_" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(context.Background(), stream)
+		client.Close()
+	})
+
+	reader := NewResultReader(client, stream, "test_api_servers", "test-api-server", 0)
+	if err := reader.EnsureGroup(ctx); err != nil {
+		t.Fatalf("ensure group: %v", err)
+	}
+	if err := client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"result": "{}"}}).Err(); err != nil {
+		t.Fatalf("add result: %v", err)
+	}
+
+	read, err := reader.Read(ctx, 10, time.Second)
+	if err != nil || len(read) != 1 || read[0].Deliveries != 1 {
+		t.Fatalf("read = %+v, %v; want one result on its first delivery", read, err)
+	}
+
+	// Left unacknowledged, the result is claimed again with its count bumped.
+	for want := int64(2); want <= 3; want++ {
+		claimed, err := reader.Claim(ctx, 10)
+		if err != nil || len(claimed) != 1 || claimed[0].Deliveries != want {
+			t.Fatalf("claim = %+v, %v; want one result on delivery %d", claimed, err, want)
+		}
+	}
+
+	if err := reader.Ack(ctx, read[0].ID); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if claimed, err := reader.Claim(ctx, 10); err != nil || len(claimed) != 0 {
+		t.Fatalf("claim after ack = %+v, %v; want none", claimed, err)
+	}
+}
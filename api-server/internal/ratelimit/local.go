@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// localBuckets is the in-process counterpart of takeScript, used when the
+// api-server runs without Redis. Buckets are per replica, so each replica
+// enforces the limit on its own.
+type localBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+	swept   time.Time
+}
+
+type localBucket struct {
+	tokens    float64
+	ts        time.Time
+	expiresAt time.Time
+}
+
+func newLocalBuckets() *localBuckets {
+	return &localBuckets{buckets: make(map[string]*localBucket)}
+}
+
+func (b *localBuckets) take(key string, limit Limit, now time.Time) *Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	rate := float64(limit.PerMinute) / 60
+	burst := float64(limit.Burst)
+
+	bucket, ok := b.buckets[key]
+	if !ok || now.After(bucket.expiresAt) {
+		bucket = &localBucket{tokens: burst, ts: now}
+		b.buckets[key] = bucket
+	}
+
+	elapsed := math.Max(0, now.Sub(bucket.ts).Seconds())
+	bucket.tokens = math.Min(burst, bucket.tokens+elapsed*rate)
+	bucket.ts = now
+	bucket.expiresAt = now.Add(time.Duration(burst / rate * float64(time.Second)))
+
+	result := &Result{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1-bucket.tokens)*1000/rate)) * time.Millisecond
+	}
+	result.Remaining = int(bucket.tokens)
+	return result
+}
+
+// sweep drops expired buckets at most once a minute so the map does not
+// grow with every client ever seen.
+func (b *localBuckets) sweep(now time.Time) {
+	if now.Sub(b.swept) < time.Minute {
+		return
+	}
+	b.swept = now
+	for key, bucket := range b.buckets {
+		if now.After(bucket.expiresAt) {
+			delete(b.buckets, key)
+		}
+	}
+}
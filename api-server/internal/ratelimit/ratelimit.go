@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "ratelimit:"
+
+// takeScript refills the bucket for the time elapsed since it was last
+// touched and takes one token from it. Each bucket is a hash of its token
+// count and refill timestamp that expires once it would be full again.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate))
+
+return {allowed, math.floor(tokens), retry_after}
+`)
+
+// Limit is a token bucket that holds Burst tokens and refills at PerMinute
+// tokens a minute.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Enabled reports whether the limit should be enforced at all.
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0 && l.Burst > 0
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter keeps token buckets in Redis so that every api-server replica
+// draws from the same bucket for a given key. Without a Redis client the
+// buckets are kept in process, per replica.
+type Limiter struct {
+	client *redis.Client
+	local  *localBuckets
+}
+
+func NewLimiter(client *redis.Client) *Limiter {
+	if client == nil {
+		return &Limiter{local: newLocalBuckets()}
+	}
+	return &Limiter{client: client}
+}
+
+// Allow takes a token from the bucket identified by key.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	if l.local != nil {
+		return l.local.take(key, limit, time.Now()), nil
+	}
+
+	rate := float64(limit.PerMinute) / 60
+	now := time.Now().UnixMilli()
+
+	values, err := takeScript.Run(ctx, l.client, []string{keyPrefix + key}, rate, limit.Burst, now).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
@@ -8,6 +10,15 @@ import (
 	"github.com/truegul/api-server/internal/model"
 )
 
+// AnalysisLogFilter narrows FindLogs. Zero-valued fields are ignored; To is
+// exclusive.
+type AnalysisLogFilter struct {
+	AnalysisID   *uuid.UUID
+	ModelVersion string
+	From         *time.Time
+	To           *time.Time
+}
+
 type AnalysisRepository struct {
 	db *gorm.DB
 }
@@ -25,7 +36,7 @@ func (r *AnalysisRepository) Create(analysis *model.Analysis) error {
 
 func (r *AnalysisRepository) FindByID(id uuid.UUID) (*model.Analysis, error) {
 	var analysis model.Analysis
-	if err := r.db.First(&analysis, "id = ?", id).Error; err != nil {
+	if err := r.db.Preload("Score").Preload("AIDetection").First(&analysis, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, apperrors.NotFound("Analysis not found")
 		}
@@ -36,7 +47,7 @@ func (r *AnalysisRepository) FindByID(id uuid.UUID) (*model.Analysis, error) {
 
 func (r *AnalysisRepository) FindByTaskID(taskID uuid.UUID) (*model.Analysis, error) {
 	var analysis model.Analysis
-	if err := r.db.First(&analysis, "task_id = ?", taskID).Error; err != nil {
+	if err := r.db.Preload("Score").Preload("AIDetection").First(&analysis, "task_id = ?", taskID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, apperrors.NotFound("Analysis not found")
 		}
@@ -47,7 +58,7 @@ func (r *AnalysisRepository) FindByTaskID(taskID uuid.UUID) (*model.Analysis, er
 
 func (r *AnalysisRepository) FindByWritingID(writingID uuid.UUID) (*model.Analysis, error) {
 	var analysis model.Analysis
-	if err := r.db.Where("writing_id = ?", writingID).Order("created_at DESC").First(&analysis).Error; err != nil {
+	if err := r.db.Preload("Score").Preload("AIDetection").Where("writing_id = ?", writingID).Order("created_at DESC").First(&analysis).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, apperrors.NotFound("Analysis not found")
 		}
@@ -56,6 +67,59 @@ func (r *AnalysisRepository) FindByWritingID(writingID uuid.UUID) (*model.Analys
 	return &analysis, nil
 }
 
+func (r *AnalysisRepository) FindAllByWritingID(writingID uuid.UUID, offset, limit int) ([]model.Analysis, int64, error) {
+	var analyses []model.Analysis
+	var total int64
+
+	query := r.db.Model(&model.Analysis{}).Where("writing_id = ?", writingID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to count analyses")
+	}
+
+	if err := query.Preload("Score").Preload("AIDetection").Order("created_at DESC").Offset(offset).Limit(limit).Find(&analyses).Error; err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to list analyses")
+	}
+
+	return analyses, total, nil
+}
+
+// FindStale returns in-flight analyses that have not changed since before.
+// Analyses whose task is still waiting in the outbox are left out: the relay
+// fails them itself if the task cannot be published in time.
+func (r *AnalysisRepository) FindStale(before time.Time, limit int) ([]model.Analysis, error) {
+	var analyses []model.Analysis
+	if err := r.db.
+		Where("status IN ?", []model.AnalysisStatus{model.AnalysisStatusPending, model.AnalysisStatusProcessing}).
+		Where("updated_at < ?", before).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM outbox_messages o
+			WHERE o.published_at IS NULL AND o.failed_at IS NULL
+			AND COALESCE(o.payload->>'task_id', o.payload->'task'->>'task_id') = analyses.task_id::text
+		)`).
+		Order("updated_at").
+		Limit(limit).
+		Find(&analyses).Error; err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to find stale analyses")
+	}
+	return analyses, nil
+}
+
+// MarkProcessing moves pending analyses whose tasks have been picked up by a
+// worker into the processing state. It leaves updated_at alone: being claimed
+// is no sign the worker is alive, and the reaper judges staleness by it.
+func (r *AnalysisRepository) MarkProcessing(taskIDs []uuid.UUID) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	if err := r.db.Model(&model.Analysis{}).
+		Where("task_id IN ? AND status = ?", taskIDs, model.AnalysisStatusPending).
+		UpdateColumn("status", model.AnalysisStatusProcessing).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to mark analyses as processing")
+	}
+	return nil
+}
+
 func (r *AnalysisRepository) Update(analysis *model.Analysis) error {
 	if err := r.db.Save(analysis).Error; err != nil {
 		return apperrors.InternalServerWrap(err, "Failed to update analysis")
@@ -63,7 +127,10 @@ func (r *AnalysisRepository) Update(analysis *model.Analysis) error {
 	return nil
 }
 
-func (r *AnalysisRepository) UpdateResult(taskID uuid.UUID, status model.AnalysisStatus, aiScore *float64, feedback *string, errorCode *model.AnalysisErrorCode, errorMessage *string, latencyMs *int) error {
+// UpdateResult moves an in-flight analysis to its final status. It reports
+// false, leaving the row alone, when the analysis has already finished or
+// been cancelled in the meantime.
+func (r *AnalysisRepository) UpdateResult(taskID uuid.UUID, status model.AnalysisStatus, aiScore *float64, feedback *string, errorCode *model.AnalysisErrorCode, errorMessage *string, latencyMs *int) (bool, error) {
 	updates := map[string]interface{}{
 		"status": status,
 	}
@@ -84,20 +151,80 @@ func (r *AnalysisRepository) UpdateResult(taskID uuid.UUID, status model.Analysi
 		updates["latency_ms"] = *latencyMs
 	}
 
-	result := r.db.Model(&model.Analysis{}).Where("task_id = ?", taskID).Updates(updates)
+	result := r.db.Model(&model.Analysis{}).
+		Where("task_id = ? AND status IN ?", taskID, []model.AnalysisStatus{model.AnalysisStatusPending, model.AnalysisStatusProcessing}).
+		Updates(updates)
 	if result.Error != nil {
-		return apperrors.InternalServerWrap(result.Error, "Failed to update analysis result")
+		return false, apperrors.InternalServerWrap(result.Error, "Failed to update analysis result")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateProgress records a heartbeat from the worker. Only in-flight analyses
+// are touched so a late heartbeat can never reopen a finished analysis.
+func (r *AnalysisRepository) UpdateProgress(taskID uuid.UUID, stage *model.AnalysisStage, progress *int) error {
+	updates := map[string]interface{}{
+		"status": model.AnalysisStatusProcessing,
+	}
+
+	if stage != nil {
+		updates["stage"] = *stage
 	}
-	if result.RowsAffected == 0 {
-		return apperrors.NotFound("Analysis not found")
+	if progress != nil {
+		updates["progress"] = *progress
+	}
+
+	result := r.db.Model(&model.Analysis{}).
+		Where("task_id = ? AND status IN ?", taskID, []model.AnalysisStatus{model.AnalysisStatusPending, model.AnalysisStatusProcessing}).
+		Updates(updates)
+	if result.Error != nil {
+		return apperrors.InternalServerWrap(result.Error, "Failed to update analysis progress")
 	}
 	return nil
 }
 
-func (r *AnalysisRepository) IncrementRetryCount(taskID uuid.UUID) error {
-	result := r.db.Model(&model.Analysis{}).Where("task_id = ?", taskID).Update("retry_count", gorm.Expr("retry_count + 1"))
+// Cancel marks an in-flight analysis cancelled. It returns false when the
+// analysis had already reached a terminal state.
+func (r *AnalysisRepository) Cancel(taskID uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.Analysis{}).
+		Where("task_id = ? AND status IN ?", taskID, []model.AnalysisStatus{model.AnalysisStatusPending, model.AnalysisStatusProcessing}).
+		Update("status", model.AnalysisStatusCancelled)
 	if result.Error != nil {
-		return apperrors.InternalServerWrap(result.Error, "Failed to increment retry count")
+		return false, apperrors.InternalServerWrap(result.Error, "Failed to cancel analysis")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// IncrementRetryCount claims the next retry of an in-flight analysis. The
+// update only applies while retry_count is still expected, so of two
+// callers deciding on the same retry exactly one gets true.
+func (r *AnalysisRepository) IncrementRetryCount(taskID uuid.UUID, expected int) (bool, error) {
+	result := r.db.Model(&model.Analysis{}).
+		Where("task_id = ? AND retry_count = ? AND status IN ?", taskID, expected, []model.AnalysisStatus{model.AnalysisStatusPending, model.AnalysisStatusProcessing}).
+		Update("retry_count", gorm.Expr("retry_count + 1"))
+	if result.Error != nil {
+		return false, apperrors.InternalServerWrap(result.Error, "Failed to increment retry count")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *AnalysisRepository) CreateScore(score *model.AnalysisScore) error {
+	if err := r.db.Create(score).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to save analysis score")
+	}
+	return nil
+}
+
+func (r *AnalysisRepository) CreateAIDetection(detection *model.AIDetection) error {
+	if err := r.db.Create(detection).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to save AI detection")
+	}
+	return nil
+}
+
+func (r *AnalysisRepository) CreateAttempt(attempt *model.AnalysisAttempt) error {
+	if err := r.db.Create(attempt).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to record analysis attempt")
 	}
 	return nil
 }
@@ -108,3 +235,32 @@ func (r *AnalysisRepository) CreateLog(log *model.AnalysisLog) error {
 	}
 	return nil
 }
+
+func (r *AnalysisRepository) FindLogs(filter AnalysisLogFilter, offset, limit int) ([]model.AnalysisLog, int64, error) {
+	var logs []model.AnalysisLog
+	var total int64
+
+	query := r.db.Model(&model.AnalysisLog{})
+	if filter.AnalysisID != nil {
+		query = query.Where("analysis_id = ?", *filter.AnalysisID)
+	}
+	if filter.ModelVersion != "" {
+		query = query.Where("model_version = ?", filter.ModelVersion)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to count analysis logs")
+	}
+
+	if err := query.Preload("Revision").Order("created_at DESC").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to list analysis logs")
+	}
+
+	return logs, total, nil
+}
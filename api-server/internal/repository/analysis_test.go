@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+func TestMarkProcessingKeepsUpdatedAt(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, NewUserRepository(db), "UTC")
+	writing := createTestWriting(t, NewWritingRepository(db), user)
+	repo := NewAnalysisRepository(db)
+
+	taskID := uuid.New()
+	quietSince := time.Now().Add(-time.Hour)
+	analysis := &model.Analysis{
+		WritingID: writing.ID,
+		TaskID:    &taskID,
+		Status:    model.AnalysisStatusPending,
+		UpdatedAt: quietSince,
+	}
+	if err := repo.Create(analysis); err != nil {
+		t.Fatalf("create analysis: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM analyses WHERE id = ?", analysis.ID)
+	})
+
+	if err := repo.MarkProcessing([]uuid.UUID{taskID}); err != nil {
+		t.Fatalf("mark processing: %v", err)
+	}
+
+	stored, err := repo.FindByTaskID(taskID)
+	if err != nil {
+		t.Fatalf("find analysis: %v", err)
+	}
+	if stored.Status != model.AnalysisStatusProcessing {
+		t.Fatalf("status = %s, want processing", stored.Status)
+	}
+	// The reaper's staleness check must still see the analysis as quiet.
+	if stored.UpdatedAt.Sub(quietSince).Abs() > time.Second {
+		t.Fatalf("updated_at = %v, want it left at %v", stored.UpdatedAt, quietSince)
+	}
+}
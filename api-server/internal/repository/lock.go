@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+)
+
+// AdvisoryLocker serializes work across replicas with Postgres session-level
+// advisory locks. The lock lives on one pooled connection that is held for
+// as long as fn runs, so it is released even if the replica dies mid-run.
+type AdvisoryLocker struct {
+	db *gorm.DB
+}
+
+func NewAdvisoryLocker(db *gorm.DB) *AdvisoryLocker {
+	return &AdvisoryLocker{db: db}
+}
+
+// TryLock runs fn while holding the advisory lock key. When another session
+// holds it, fn is skipped and TryLock reports false.
+func (l *AdvisoryLocker) TryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	acquired := false
+	var fnErr error
+
+	err := l.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", key).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", key)
+
+		fnErr = fn(ctx)
+		return nil
+	})
+	if err != nil {
+		return false, apperrors.InternalServerWrap(err, "Failed to take advisory lock")
+	}
+	return acquired, fnErr
+}
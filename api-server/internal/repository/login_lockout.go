@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/model"
+)
+
+type LoginLockoutRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginLockoutRepository(db *gorm.DB) *LoginLockoutRepository {
+	return &LoginLockoutRepository{db: db}
+}
+
+func (r *LoginLockoutRepository) Create(lockout *model.LoginLockout) error {
+	if err := r.db.Create(lockout).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to record login lockout")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/model"
+)
+
+// ErrUndeliverable marks a publish failure that no retry can fix, such as an
+// undecodable payload. ProcessBatch sets such messages aside at once.
+var ErrUndeliverable = errors.New("outbox message cannot be delivered")
+
+const (
+	outboxBaseBackoff = time.Second
+	outboxMaxBackoff  = 5 * time.Minute
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+func (r *OutboxRepository) Enqueue(kind model.OutboxKind, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to encode outbox message")
+	}
+
+	message := &model.OutboxMessage{
+		Kind:    kind,
+		Payload: string(payloadJSON),
+	}
+	if err := r.db.Create(message).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to enqueue outbox message")
+	}
+	return nil
+}
+
+// ProcessBatch locks up to limit pending messages in insertion order and
+// hands them to publish one by one. Successfully published messages are marked
+// as such. A failure is recorded on its message, which is not tried again
+// before an exponential backoff has passed, and ends the batch so that
+// ordering is preserved; so does reaching a message still backing off. A
+// message that failed with ErrUndeliverable, or that is still failing maxAge
+// after it was written, is marked failed and returned instead, and the batch
+// moves on past it. Rows locked by another relay are skipped.
+func (r *OutboxRepository) ProcessBatch(limit int, maxAge time.Duration, publish func(*model.OutboxMessage) error) (int, []model.OutboxMessage, error) {
+	published := 0
+	var failed []model.OutboxMessage
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var messages []model.OutboxMessage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND failed_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+
+		for i := range messages {
+			message := &messages[i]
+			now := time.Now()
+			if message.NextAttemptAt != nil && message.NextAttemptAt.After(now) {
+				return nil
+			}
+
+			if publishErr := publish(message); publishErr != nil {
+				updates := map[string]interface{}{
+					"attempts":        gorm.Expr("attempts + 1"),
+					"last_error":      publishErr.Error(),
+					"next_attempt_at": now.Add(outboxBackoff(message.Attempts)),
+				}
+				setAside := errors.Is(publishErr, ErrUndeliverable) || now.Sub(message.CreatedAt) >= maxAge
+				if setAside {
+					updates["failed_at"] = now
+				}
+				if err := tx.Model(message).Updates(updates).Error; err != nil {
+					return err
+				}
+				if !setAside {
+					return nil
+				}
+				failed = append(failed, *message)
+				continue
+			}
+
+			if err := tx.Model(message).Update("published_at", now).Error; err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, apperrors.InternalServerWrap(err, "Failed to relay outbox messages")
+	}
+	return published, failed, nil
+}
+
+// outboxBackoff is the wait after a message's attempts+1-th failure: it
+// doubles from outboxBaseBackoff up to outboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts >= 20 {
+		return outboxMaxBackoff
+	}
+	backoff := outboxBaseBackoff << attempts
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
+
+// Lag reports how many messages are waiting to be published and when the
+// oldest of them was written.
+func (r *OutboxRepository) Lag() (int64, *time.Time, error) {
+	var stats struct {
+		Pending int64
+		Oldest  *time.Time
+	}
+	if err := r.db.Model(&model.OutboxMessage{}).
+		Select("COUNT(*) AS pending, MIN(created_at) AS oldest").
+		Where("published_at IS NULL AND failed_at IS NULL").
+		Scan(&stats).Error; err != nil {
+		return 0, nil, apperrors.InternalServerWrap(err, "Failed to measure outbox lag")
+	}
+	return stats.Pending, stats.Oldest, nil
+}
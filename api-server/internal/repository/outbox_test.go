@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+func TestProcessBatchBacksOffTransientFailures(t *testing.T) {
+	db := testDB(t)
+	repo := NewOutboxRepository(db)
+	if err := db.Exec("DELETE FROM outbox_messages WHERE published_at IS NULL AND failed_at IS NULL").Error; err != nil {
+		t.Fatalf("clear outbox: %v", err)
+	}
+
+	if err := repo.Enqueue(model.OutboxKindAnalysisCancel, map[string]string{"task_id": "test"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM outbox_messages WHERE payload->>'task_id' = 'test'")
+	})
+
+	calls := 0
+	failing := func(*model.OutboxMessage) error {
+		calls++
+		return errors.New("queue unavailable")
+	}
+
+	published, failed, err := repo.ProcessBatch(10, time.Hour, failing)
+	if err != nil || published != 0 || len(failed) != 0 || calls != 1 {
+		t.Fatalf("first batch = %d, %v, %v after %d calls; want one kept failure", published, failed, err, calls)
+	}
+
+	// The message is backing off, so the next tick leaves it alone.
+	if _, _, err := repo.ProcessBatch(10, time.Hour, failing); err != nil || calls != 1 {
+		t.Fatalf("second batch: err %v after %d calls; want no new attempt", err, calls)
+	}
+
+	// Once the backoff has passed, a message older than maxAge is set aside.
+	if err := db.Exec("UPDATE outbox_messages SET next_attempt_at = NOW() WHERE payload->>'task_id' = 'test'").Error; err != nil {
+		t.Fatalf("expire backoff: %v", err)
+	}
+	_, failed, err = repo.ProcessBatch(10, 0, failing)
+	if err != nil || len(failed) != 1 || calls != 2 {
+		t.Fatalf("third batch = %v, %v after %d calls; want the message set aside", failed, err, calls)
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/model"
+	"gorm.io/gorm"
+)
+
+type PlanRepository struct {
+	db *gorm.DB
+}
+
+func NewPlanRepository(db *gorm.DB) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+func (r *PlanRepository) FindAll() ([]*data.Plan, error) {
+	var plans []model.Plan
+	if err := r.db.Order("daily_submission_limit, code").Find(&plans).Error; err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to list plans")
+	}
+
+	result := make([]*data.Plan, len(plans))
+	for i := range plans {
+		result[i] = toPlanData(&plans[i])
+	}
+	return result, nil
+}
+
+func (r *PlanRepository) FindByCode(code string) (*data.Plan, error) {
+	var m model.Plan
+	if err := r.db.Where("code = ?", code).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("Plan not found")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to find plan")
+	}
+	return toPlanData(&m), nil
+}
+
+// FindByUserID returns the plan the user is currently assigned to.
+func (r *PlanRepository) FindByUserID(userID uuid.UUID) (*data.Plan, error) {
+	var m model.Plan
+	err := r.db.
+		Joins("JOIN users ON users.plan_code = plans.code").
+		Where("users.id = ?", userID).
+		First(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("User not found")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to find user plan")
+	}
+	return toPlanData(&m), nil
+}
+
+func toPlanData(m *model.Plan) *data.Plan {
+	features := make([]data.PlanFeature, len(m.Features))
+	for i, f := range m.Features {
+		features[i] = data.PlanFeature(f)
+	}
+
+	return &data.Plan{
+		Code:                   m.Code,
+		Name:                   m.Name,
+		DailySubmissionLimit:   m.DailySubmissionLimit,
+		MonthlySubmissionLimit: m.MonthlySubmissionLimit,
+		MaxContentLength:       m.MaxContentLength,
+		Features:               features,
+	}
+}
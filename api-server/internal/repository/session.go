@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/model"
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(session *data.Session) error {
+	m := toSessionModel(session)
+	if err := r.db.Create(m).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to create session")
+	}
+	session.ID = m.ID
+	session.CreatedAt = m.CreatedAt
+	session.LastSeenAt = m.LastSeenAt
+	return nil
+}
+
+func (r *SessionRepository) FindByID(id uuid.UUID) (*data.Session, error) {
+	var m model.Session
+	if err := r.db.Where("id = ?", id).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("Session not found")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to find session")
+	}
+	return toSessionData(&m), nil
+}
+
+// FindActiveByUserID lists the user's sessions that have been neither revoked
+// nor left to expire, most recently used first.
+func (r *SessionRepository) FindActiveByUserID(userID uuid.UUID) ([]*data.Session, error) {
+	var sessions []model.Session
+	if err := r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to list sessions")
+	}
+
+	result := make([]*data.Session, len(sessions))
+	for i := range sessions {
+		result[i] = toSessionData(&sessions[i])
+	}
+	return result, nil
+}
+
+// Touch records that the session was just used.
+func (r *SessionRepository) Touch(id uuid.UUID) error {
+	if err := r.db.Model(&model.Session{}).Where("id = ?", id).Update("last_seen_at", time.Now()).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to update session")
+	}
+	return nil
+}
+
+// Extend slides the session's expiry forward, as happens on every refresh.
+func (r *SessionRepository) Extend(id uuid.UUID, expiresAt time.Time) error {
+	if err := r.db.Model(&model.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"expires_at":   expiresAt,
+		"last_seen_at": time.Now(),
+	}).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to extend session")
+	}
+	return nil
+}
+
+// Revoke ends the session. Revoking an already revoked session keeps its
+// original reason.
+func (r *SessionRepository) Revoke(id uuid.UUID, reason data.SessionRevokedReason) error {
+	if err := r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to revoke session")
+	}
+	return nil
+}
+
+// RevokeAllForUser ends every live session of the user and returns how many
+// were revoked.
+func (r *SessionRepository) RevokeAllForUser(userID uuid.UUID, reason data.SessionRevokedReason) (int64, error) {
+	result := r.db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return 0, apperrors.InternalServerWrap(result.Error, "Failed to revoke sessions")
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *SessionRepository) CreateRefreshToken(sessionID uuid.UUID, tokenHash string) error {
+	if err := r.db.Create(&model.RefreshToken{
+		TokenHash: tokenHash,
+		SessionID: sessionID,
+	}).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to create refresh token")
+	}
+	return nil
+}
+
+func (r *SessionRepository) FindRefreshToken(tokenHash string) (*model.RefreshToken, error) {
+	var m model.RefreshToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("Refresh token not found")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to find refresh token")
+	}
+	return &m, nil
+}
+
+// RotateRefreshToken marks the token as used. It returns false if the token
+// had already been rotated, which means it is being replayed.
+func (r *SessionRepository) RotateRefreshToken(tokenHash string) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("token_hash = ? AND rotated_at IS NULL", tokenHash).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		return false, apperrors.InternalServerWrap(result.Error, "Failed to rotate refresh token")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func toSessionModel(d *data.Session) *model.Session {
+	m := &model.Session{
+		ID:         d.ID,
+		UserID:     d.UserID,
+		UserAgent:  optionalString(d.UserAgent),
+		IPAddress:  optionalString(d.IPAddress),
+		CreatedAt:  d.CreatedAt,
+		LastSeenAt: d.LastSeenAt,
+		ExpiresAt:  d.ExpiresAt,
+		RevokedAt:  d.RevokedAt,
+	}
+	if d.RevokedReason != nil {
+		reason := string(*d.RevokedReason)
+		m.RevokedReason = &reason
+	}
+	return m
+}
+
+func toSessionData(m *model.Session) *data.Session {
+	d := &data.Session{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		CreatedAt:  m.CreatedAt,
+		LastSeenAt: m.LastSeenAt,
+		ExpiresAt:  m.ExpiresAt,
+		RevokedAt:  m.RevokedAt,
+	}
+	if m.UserAgent != nil {
+		d.UserAgent = *m.UserAgent
+	}
+	if m.IPAddress != nil {
+		d.IPAddress = *m.IPAddress
+	}
+	if m.RevokedReason != nil {
+		reason := data.SessionRevokedReason(*m.RevokedReason)
+		d.RevokedReason = &reason
+	}
+	return d
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+)
+
+// Tx exposes repositories bound to a single database transaction.
+type Tx struct {
+	Analyses *AnalysisRepository
+	Writings *WritingRepository
+	Users    *UserRepository
+	Outbox   *OutboxRepository
+	Sessions *SessionRepository
+}
+
+type Transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// WithinTransaction runs fn in a transaction that is committed when fn
+// returns nil and rolled back otherwise.
+func (t *Transactor) WithinTransaction(fn func(tx *Tx) error) error {
+	err := t.db.Transaction(func(db *gorm.DB) error {
+		return fn(&Tx{
+			Analyses: NewAnalysisRepository(db),
+			Writings: NewWritingRepository(db),
+			Users:    NewUserRepository(db),
+			Outbox:   NewOutboxRepository(db),
+			Sessions: NewSessionRepository(db),
+		})
+	})
+	if err == nil {
+		return nil
+	}
+	if _, ok := apperrors.IsAppError(err); ok {
+		return err
+	}
+	return apperrors.InternalServerWrap(err, "Failed to commit transaction")
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/truegul/api-server/internal/data"
@@ -71,26 +72,132 @@ func (r *UserRepository) Update(user *data.User) error {
 	return nil
 }
 
+// userToday is the current calendar date in the user's own timezone, which
+// is where a submission day begins and ends. userMonth is the first day of
+// the user's current calendar month.
+const (
+	userToday = "(now() AT TIME ZONE timezone)::date"
+	userMonth = "date_trunc('month', now() AT TIME ZONE timezone)::date"
+)
+
+// ConsumeSubmission atomically takes one of the user's daily and monthly
+// submissions. The daily count starts afresh on the first submission of the
+// user's local day, the monthly count on the first submission of the local
+// month. The checks and the increments are a single conditional UPDATE, so
+// concurrent submissions can never push either count past its limit. It
+// returns false when a limit has already been reached.
+func (r *UserRepository) ConsumeSubmission(userID uuid.UUID, dailyLimit, monthlyLimit int) (*data.SubmissionUsage, bool, error) {
+	var usages []data.SubmissionUsage
+	err := r.db.Raw(`
+		UPDATE users SET
+			daily_submit_count = CASE WHEN last_submit_date = `+userToday+` THEN daily_submit_count + 1 ELSE 1 END,
+			monthly_submit_count = CASE WHEN last_submit_date >= `+userMonth+` THEN monthly_submit_count + 1 ELSE 1 END,
+			last_submit_date = `+userToday+`,
+			updated_at = now()
+		WHERE id = ?
+			AND (last_submit_date IS DISTINCT FROM `+userToday+` OR daily_submit_count < ?)
+			AND (last_submit_date IS NULL OR last_submit_date < `+userMonth+` OR monthly_submit_count < ?)
+		RETURNING daily_submit_count AS daily, monthly_submit_count AS monthly`, userID, dailyLimit, monthlyLimit).
+		Scan(&usages).Error
+	if err != nil {
+		return nil, false, apperrors.InternalServerWrap(err, "Failed to update submission quota")
+	}
+	if len(usages) == 0 {
+		return nil, false, nil
+	}
+	return &usages[0], true, nil
+}
+
+// SubmissionUsage reports the user's submissions so far in their current
+// local day and month.
+func (r *UserRepository) SubmissionUsage(userID uuid.UUID) (*data.SubmissionUsage, error) {
+	var usages []data.SubmissionUsage
+	err := r.db.Raw(`
+		SELECT
+			CASE WHEN last_submit_date = `+userToday+` THEN daily_submit_count ELSE 0 END AS daily,
+			CASE WHEN last_submit_date >= `+userMonth+` THEN monthly_submit_count ELSE 0 END AS monthly
+		FROM users
+		WHERE id = ?`, userID).
+		Scan(&usages).Error
+	if err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to read submission quota")
+	}
+	if len(usages) == 0 {
+		return nil, apperrors.NotFound("User not found")
+	}
+	return &usages[0], nil
+}
+
+// RefundSubmission gives back the submission taken at submittedAt. Each
+// counter is only decremented while it still belongs to the local day or
+// month of the submission; a counter that has rolled over is left untouched.
+func (r *UserRepository) RefundSubmission(userID uuid.UUID, submittedAt time.Time) error {
+	const submittedDay = "(?::timestamptz AT TIME ZONE timezone)::date"
+
+	if err := r.db.Exec(`
+		UPDATE users SET
+			daily_submit_count = CASE
+				WHEN last_submit_date = `+submittedDay+` AND daily_submit_count > 0 THEN daily_submit_count - 1
+				ELSE daily_submit_count END,
+			monthly_submit_count = CASE
+				WHEN date_trunc('month', last_submit_date) = date_trunc('month', `+submittedDay+`) AND monthly_submit_count > 0 THEN monthly_submit_count - 1
+				ELSE monthly_submit_count END
+		WHERE id = ?`, submittedAt, submittedAt, userID).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to refund submission")
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdatePlan(userID uuid.UUID, planCode string) error {
+	result := r.db.Model(&model.User{}).Where("id = ?", userID).Update("plan_code", planCode)
+	if result.Error != nil {
+		return apperrors.InternalServerWrap(result.Error, "Failed to update plan")
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("User not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateTimezone(userID uuid.UUID, timezone string) error {
+	result := r.db.Model(&model.User{}).Where("id = ?", userID).Update("timezone", timezone)
+	if result.Error != nil {
+		return apperrors.InternalServerWrap(result.Error, "Failed to update timezone")
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("User not found")
+	}
+	return nil
+}
+
 func toModel(d *data.User) *model.User {
 	return &model.User{
-		ID:               d.ID,
-		Email:            d.Email,
-		PasswordHash:     d.PasswordHash,
-		DailySubmitCount: d.DailySubmitCount,
-		LastSubmitDate:   d.LastSubmitDate,
-		CreatedAt:        d.CreatedAt,
-		UpdatedAt:        d.UpdatedAt,
+		ID:                 d.ID,
+		Email:              d.Email,
+		PasswordHash:       d.PasswordHash,
+		Role:               string(d.Role),
+		Timezone:           d.Timezone,
+		PlanCode:           d.PlanCode,
+		DailySubmitCount:   d.DailySubmitCount,
+		MonthlySubmitCount: d.MonthlySubmitCount,
+		LastSubmitDate:     d.LastSubmitDate,
+		CreatedAt:          d.CreatedAt,
+		UpdatedAt:          d.UpdatedAt,
 	}
 }
 
 func toData(m *model.User) *data.User {
 	return &data.User{
-		ID:               m.ID,
-		Email:            m.Email,
-		PasswordHash:     m.PasswordHash,
-		DailySubmitCount: m.DailySubmitCount,
-		LastSubmitDate:   m.LastSubmitDate,
-		CreatedAt:        m.CreatedAt,
-		UpdatedAt:        m.UpdatedAt,
+		ID:                 m.ID,
+		Email:              m.Email,
+		PasswordHash:       m.PasswordHash,
+		Role:               data.UserRole(m.Role),
+		Timezone:           m.Timezone,
+		PlanCode:           m.PlanCode,
+		DailySubmitCount:   m.DailySubmitCount,
+		MonthlySubmitCount: m.MonthlySubmitCount,
+		LastSubmitDate:     m.LastSubmitDate,
+		CreatedAt:          m.CreatedAt,
+		UpdatedAt:          m.UpdatedAt,
 	}
 }
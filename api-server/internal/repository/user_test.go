@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/truegul/api-server/internal/data"
+	"github.com/truegul/api-server/internal/migrations"
+)
+
+// testDB connects to the database named by TEST_DATABASE_URL and brings its
+// schema up to date. Tests that need it are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	source, err := iofs.New(migrations.FS, migrations.Dir)
+	if err != nil {
+		t.Fatalf("open migrations: %v", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return db
+}
+
+func createTestUser(t *testing.T, repo *UserRepository, timezone string) *data.User {
+	t.Helper()
+
+	user := &data.User{
+		Email:        fmt.Sprintf("quota-%s@example.com", uuid.NewString()),
+		PasswordHash: "x",
+		Role:         data.UserRoleUser,
+		Timezone:     timezone,
+		PlanCode:     data.DefaultPlanCode,
+	}
+	if err := repo.Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		repo.db.Exec("DELETE FROM users WHERE id = ?", user.ID)
+	})
+	return user
+}
+
+func TestConsumeSubmissionConcurrent(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserRepository(db)
+	user := createTestUser(t, repo, "Asia/Seoul")
+
+	const (
+		limit        = 5
+		monthlyLimit = 100
+		callers      = 50
+	)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+		counts   = make(map[int]bool)
+	)
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			usage, ok, err := repo.ConsumeSubmission(user.ID, limit, monthlyLimit)
+			if err != nil {
+				t.Errorf("consume: %v", err)
+				return
+			}
+			if !ok {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			accepted++
+			if counts[usage.Daily] {
+				t.Errorf("count %d handed out twice", usage.Daily)
+			}
+			counts[usage.Daily] = true
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if accepted != limit {
+		t.Fatalf("accepted %d submissions, want %d", accepted, limit)
+	}
+
+	stored, err := repo.FindByID(user.ID)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	if stored.DailySubmitCount != limit {
+		t.Fatalf("daily_submit_count = %d, want %d", stored.DailySubmitCount, limit)
+	}
+}
+
+func TestConsumeSubmissionResetsOnUserLocalDay(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserRepository(db)
+	user := createTestUser(t, repo, "America/Los_Angeles")
+
+	// Exhaust the quota on what was yesterday in the user's timezone.
+	if err := db.Exec(`
+		UPDATE users
+		SET daily_submit_count = 5, last_submit_date = (now() AT TIME ZONE timezone)::date - 1
+		WHERE id = ?`, user.ID).Error; err != nil {
+		t.Fatalf("seed quota: %v", err)
+	}
+
+	usage, ok, err := repo.ConsumeSubmission(user.ID, 5, 100)
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if !ok || usage.Daily != 1 {
+		t.Fatalf("ConsumeSubmission = (%+v, %v), want daily 1", usage, ok)
+	}
+}
+
+func TestConsumeSubmissionMonthlyLimit(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserRepository(db)
+	user := createTestUser(t, repo, "UTC")
+
+	if err := db.Exec(`
+		UPDATE users
+		SET daily_submit_count = 0, monthly_submit_count = 60, last_submit_date = (now() AT TIME ZONE timezone)::date
+		WHERE id = ?`, user.ID).Error; err != nil {
+		t.Fatalf("seed quota: %v", err)
+	}
+
+	_, ok, err := repo.ConsumeSubmission(user.ID, 5, 60)
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if ok {
+		t.Fatal("ConsumeSubmission accepted a submission past the monthly limit")
+	}
+}
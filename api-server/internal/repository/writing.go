@@ -2,12 +2,14 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/truegul/api-server/internal/data"
 	apperrors "github.com/truegul/api-server/internal/errors"
 	"github.com/truegul/api-server/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WritingRepository struct {
@@ -63,15 +65,28 @@ func (r *WritingRepository) FindByUserID(userID uuid.UUID, offset, limit int) ([
 	return result, total, nil
 }
 
-func (r *WritingRepository) Update(writing *data.Writing) error {
+// Update writes the writing's type, title, content and status, provided it
+// is still in status expected. It reports false when it is not, so an edit
+// based on an older copy never overwrites a concurrent state change.
+func (r *WritingRepository) Update(writing *data.Writing, expected data.WritingStatus) (bool, error) {
 	m := toWritingModel(writing)
-	if err := r.db.Save(m).Error; err != nil {
-		return apperrors.InternalServerWrap(err, "Failed to update writing")
+	result := r.db.Model(m).
+		Where("status = ?", model.WritingStatus(expected)).
+		Updates(map[string]interface{}{
+			"type":    m.Type,
+			"title":   m.Title,
+			"content": m.Content,
+			"status":  m.Status,
+		})
+	if result.Error != nil {
+		return false, apperrors.InternalServerWrap(result.Error, "Failed to update writing")
 	}
 	writing.UpdatedAt = m.UpdatedAt
-	return nil
+	return result.RowsAffected > 0, nil
 }
 
+// Delete moves the writing to the trash. Trashed writings are excluded from
+// every other query except the trash-specific ones below.
 func (r *WritingRepository) Delete(id uuid.UUID) error {
 	if err := r.db.Delete(&model.Writing{}, "id = ?", id).Error; err != nil {
 		return apperrors.InternalServerWrap(err, "Failed to delete writing")
@@ -79,6 +94,143 @@ func (r *WritingRepository) Delete(id uuid.UUID) error {
 	return nil
 }
 
+func (r *WritingRepository) FindTrashedByID(id uuid.UUID) (*data.Writing, error) {
+	var m model.Writing
+	err := r.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("Writing not found in trash")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to find writing")
+	}
+	return toWritingData(&m), nil
+}
+
+func (r *WritingRepository) FindTrashedByUserID(userID uuid.UUID, offset, limit int) ([]*data.Writing, int64, error) {
+	var writings []model.Writing
+	var total int64
+
+	query := r.db.Unscoped().Model(&model.Writing{}).Where("user_id = ? AND deleted_at IS NOT NULL", userID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to count trashed writings")
+	}
+
+	if err := query.Order("deleted_at DESC").Offset(offset).Limit(limit).Find(&writings).Error; err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to list trashed writings")
+	}
+
+	result := make([]*data.Writing, len(writings))
+	for i, w := range writings {
+		result[i] = toWritingData(&w)
+	}
+
+	return result, total, nil
+}
+
+func (r *WritingRepository) Restore(id uuid.UUID) error {
+	if err := r.db.Unscoped().Model(&model.Writing{}).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+		return apperrors.InternalServerWrap(err, "Failed to restore writing")
+	}
+	return nil
+}
+
+// PurgeDeletedBefore permanently removes up to limit writings trashed before
+// the given time, together with their analyses through ON DELETE CASCADE.
+func (r *WritingRepository) PurgeDeletedBefore(before time.Time, limit int) (int64, error) {
+	result := r.db.Unscoped().
+		Where("id IN (?)", r.db.Unscoped().Model(&model.Writing{}).
+			Select("id").
+			Where("deleted_at < ?", before).
+			Limit(limit)).
+		Delete(&model.Writing{})
+	if result.Error != nil {
+		return 0, apperrors.InternalServerWrap(result.Error, "Failed to purge trashed writings")
+	}
+	return result.RowsAffected, nil
+}
+
+// TransitionStatus moves the writing from status from to status to, writing
+// only the status columns. It reports false when the writing is no longer in
+// from, so callers holding an older copy can tell that they lost a race.
+// Moving to submitted also stamps submitted_at.
+func (r *WritingRepository) TransitionStatus(id uuid.UUID, from, to data.WritingStatus) (bool, error) {
+	updates := map[string]interface{}{"status": model.WritingStatus(to)}
+	if to == data.WritingStatusSubmitted {
+		updates["submitted_at"] = time.Now()
+	}
+
+	result := r.db.Model(&model.Writing{}).
+		Where("id = ? AND status = ?", id, model.WritingStatus(from)).
+		Updates(updates)
+	if result.Error != nil {
+		return false, apperrors.InternalServerWrap(result.Error, "Failed to update writing status")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateRevision snapshots the writing's current type, title and content as
+// its next revision. The writing's row is locked and read first so that the
+// snapshot is the committed content and concurrent callers number their
+// revisions one after the other instead of colliding on the same number; it
+// must therefore run inside a transaction.
+func (r *WritingRepository) CreateRevision(writingID uuid.UUID) (*data.WritingRevision, error) {
+	var writing model.Writing
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", writingID).
+		First(&writing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("Writing not found")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to lock writing")
+	}
+
+	var latest int
+	if err := r.db.Model(&model.WritingRevision{}).
+		Where("writing_id = ?", writingID).
+		Select("COALESCE(MAX(revision_number), 0)").
+		Scan(&latest).Error; err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to determine revision number")
+	}
+
+	m := &model.WritingRevision{
+		WritingID:      writingID,
+		RevisionNumber: latest + 1,
+		Type:           writing.Type,
+		Title:          writing.Title,
+		Content:        writing.Content,
+	}
+	if err := r.db.Create(m).Error; err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to create writing revision")
+	}
+	return toRevisionData(m), nil
+}
+
+func (r *WritingRepository) FindRevisionByID(id uuid.UUID) (*data.WritingRevision, error) {
+	var m model.WritingRevision
+	err := r.db.Where("id = ?", id).First(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.NotFound("Writing revision not found")
+		}
+		return nil, apperrors.InternalServerWrap(err, "Failed to find writing revision")
+	}
+	return toRevisionData(&m), nil
+}
+
+func (r *WritingRepository) FindRevisions(writingID uuid.UUID) ([]*data.WritingRevision, error) {
+	var revisions []model.WritingRevision
+	if err := r.db.Where("writing_id = ?", writingID).Order("revision_number DESC").Find(&revisions).Error; err != nil {
+		return nil, apperrors.InternalServerWrap(err, "Failed to list writing revisions")
+	}
+
+	result := make([]*data.WritingRevision, len(revisions))
+	for i := range revisions {
+		result[i] = toRevisionData(&revisions[i])
+	}
+	return result, nil
+}
+
 func toWritingModel(d *data.Writing) *model.Writing {
 	return &model.Writing{
 		ID:          d.ID,
@@ -90,6 +242,7 @@ func toWritingModel(d *data.Writing) *model.Writing {
 		CreatedAt:   d.CreatedAt,
 		UpdatedAt:   d.UpdatedAt,
 		SubmittedAt: d.SubmittedAt,
+		DeletedAt:   toDeletedAt(d.DeletedAt),
 	}
 }
 
@@ -104,5 +257,32 @@ func toWritingData(m *model.Writing) *data.Writing {
 		CreatedAt:   m.CreatedAt,
 		UpdatedAt:   m.UpdatedAt,
 		SubmittedAt: m.SubmittedAt,
+		DeletedAt:   fromDeletedAt(m.DeletedAt),
+	}
+}
+
+func toDeletedAt(t *time.Time) gorm.DeletedAt {
+	if t == nil {
+		return gorm.DeletedAt{}
+	}
+	return gorm.DeletedAt{Time: *t, Valid: true}
+}
+
+func fromDeletedAt(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Time
+}
+
+func toRevisionData(m *model.WritingRevision) *data.WritingRevision {
+	return &data.WritingRevision{
+		ID:             m.ID,
+		WritingID:      m.WritingID,
+		RevisionNumber: m.RevisionNumber,
+		Type:           data.WritingType(m.Type),
+		Title:          m.Title,
+		Content:        m.Content,
+		CreatedAt:      m.CreatedAt,
 	}
 }
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/truegul/api-server/internal/data"
+)
+
+func createTestWriting(t *testing.T, repo *WritingRepository, user *data.User) *data.Writing {
+	t.Helper()
+
+	writing := &data.Writing{
+		UserID:  user.ID,
+		Type:    data.WritingTypeEssay,
+		Title:   "test",
+		Content: "테스트 글입니다.",
+		Status:  data.WritingStatusDraft,
+	}
+	if err := repo.Create(writing); err != nil {
+		t.Fatalf("create writing: %v", err)
+	}
+	t.Cleanup(func() {
+		repo.db.Unscoped().Exec("DELETE FROM writings WHERE id = ?", writing.ID)
+	})
+	return writing
+}
+
+func TestTransitionStatusConcurrent(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, NewUserRepository(db), "UTC")
+	repo := NewWritingRepository(db)
+	writing := createTestWriting(t, repo, user)
+
+	const callers = 20
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		moved int
+	)
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			ok, err := repo.TransitionStatus(writing.ID, data.WritingStatusDraft, data.WritingStatusSubmitted)
+			if err != nil {
+				t.Errorf("transition: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				moved++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if moved != 1 {
+		t.Fatalf("%d callers moved the writing, want 1", moved)
+	}
+
+	stored, err := repo.FindByID(writing.ID)
+	if err != nil {
+		t.Fatalf("find writing: %v", err)
+	}
+	if stored.Status != data.WritingStatusSubmitted || stored.SubmittedAt == nil {
+		t.Fatalf("writing = %+v, want submitted with submitted_at", stored)
+	}
+	if stored.Content != writing.Content || stored.Title != writing.Title {
+		t.Fatalf("transition changed the writing's content: %+v", stored)
+	}
+}
+
+func TestUpdateRejectsStaleStatus(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, NewUserRepository(db), "UTC")
+	repo := NewWritingRepository(db)
+	writing := createTestWriting(t, repo, user)
+
+	// Another request submits the writing after this one read it as a draft.
+	if ok, err := repo.TransitionStatus(writing.ID, data.WritingStatusDraft, data.WritingStatusSubmitted); err != nil || !ok {
+		t.Fatalf("transition = %v, %v; want true, nil", ok, err)
+	}
+
+	stale := *writing
+	stale.Content = "바뀐 글입니다."
+	updated, err := repo.Update(&stale, data.WritingStatusDraft)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated {
+		t.Fatal("Update applied an edit based on a stale status")
+	}
+
+	stored, err := repo.FindByID(writing.ID)
+	if err != nil {
+		t.Fatalf("find writing: %v", err)
+	}
+	if stored.Status != data.WritingStatusSubmitted || stored.Content != writing.Content {
+		t.Fatalf("writing = %+v, want the submitted original", stored)
+	}
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Result is a status report from an ML worker about one task, as sent to the
+// callback endpoint or the results stream. The same shape serves every
+// version; the version decides which parts are required.
+type Result struct {
+	Version      string    `json:"version" binding:"required"`
+	TaskID       string    `json:"task_id" binding:"required,uuid"`
+	Status       string    `json:"status" binding:"required,oneof=processing completed failed"`
+	ModelVersion string    `json:"model_version,omitempty" binding:"omitempty,max=50"`
+	Progress     *Progress `json:"progress,omitempty"`
+	Output       *Output   `json:"result,omitempty"`
+	Error        *Error    `json:"error,omitempty"`
+}
+
+type Progress struct {
+	Stage   string `json:"stage,omitempty" binding:"omitempty,oneof=ai_detection llm_scoring"`
+	Percent *int   `json:"percent,omitempty" binding:"omitempty,min=0,max=100"`
+}
+
+// Output is the outcome of a completed analysis.
+type Output struct {
+	AIProbability float64      `json:"ai_probability"`
+	Feedback      string       `json:"feedback"`
+	LatencyMs     int          `json:"latency_ms"`
+	Rubric        *Rubric      `json:"rubric,omitempty"`
+	AIDetection   *AIDetection `json:"ai_detection,omitempty"`
+}
+
+// Rubric is the per-criterion TOPIK 54 scoring. It is optional in v1 and
+// required of completed v2 results.
+type Rubric struct {
+	Content       Criterion `json:"content"`
+	Structure     Criterion `json:"structure"`
+	Language      Criterion `json:"language"`
+	Total         int       `json:"total" binding:"min=0"`
+	LevelEstimate string    `json:"level_estimate"`
+	Suggestions   []string  `json:"suggestions"`
+}
+
+type Criterion struct {
+	Score      int      `json:"score" binding:"min=0"`
+	Feedback   string   `json:"feedback"`
+	Deductions []string `json:"deductions"`
+}
+
+type AIDetection struct {
+	Score        float64    `json:"score" binding:"min=0,max=1"`
+	ModelVersion string     `json:"model_version" binding:"required,max=50"`
+	DetectedAt   *time.Time `json:"detected_at,omitempty"`
+}
+
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// DecodeResult parses and validates a result. Results with an unknown major
+// version fail with an UnsupportedVersionError before anything else is
+// looked at.
+func DecodeResult(data []byte) (*Result, error) {
+	version, err := peekVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	major, err := Major(version)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(&result); err != nil {
+		return nil, err
+	}
+
+	if major == V2 && result.Status == "completed" {
+		if result.Output == nil {
+			return nil, errors.New("completed version 2 results must include a result")
+		}
+		if result.Output.Rubric == nil {
+			return nil, errors.New("completed version 2 results must include a rubric")
+		}
+	}
+	return &result, nil
+}
@@ -0,0 +1,89 @@
+// Package schema defines the wire formats exchanged with the ML workers:
+// analysis tasks going out and results coming back.
+//
+// Versions are written "MAJOR" or "MAJOR.MINOR". A minor revision only adds
+// optional fields, so any minor of a supported major is accepted; a message
+// with an unknown major is rejected with an UnsupportedVersionError.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// V1 tasks carry the writing as plain text; v1 results carry the AI
+	// probability and free-text feedback, optionally with a rubric.
+	V1 = "1"
+	// V2 tasks wrap the writing in a typed input, leaving room for OCR of
+	// handwritten answers, and name the scoring to apply; v2 results must
+	// carry the rubric for completed analyses.
+	V2 = "2"
+)
+
+// Supported lists the versions this server reads and writes, oldest first.
+var Supported = []string{V1, V2}
+
+// UnsupportedVersionError reports a message whose version this server does
+// not understand.
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported schema version %q (supported major versions: %s)", e.Version, strings.Join(Supported, ", "))
+}
+
+// Major returns the major version of version, which must be supported.
+func Major(version string) (string, error) {
+	major, minor, hasMinor := strings.Cut(version, ".")
+	if _, err := strconv.ParseUint(major, 10, 32); err != nil {
+		return "", &UnsupportedVersionError{Version: version}
+	}
+	if hasMinor {
+		if _, err := strconv.ParseUint(minor, 10, 32); err != nil {
+			return "", &UnsupportedVersionError{Version: version}
+		}
+	}
+
+	for _, supported := range Supported {
+		if major == supported {
+			return major, nil
+		}
+	}
+	return "", &UnsupportedVersionError{Version: version}
+}
+
+// Negotiate picks the newest supported version among those the workers
+// accept, so that tasks are published in a format every worker can read.
+func Negotiate(accepted []string) (string, error) {
+	majors := make(map[string]bool, len(accepted))
+	for _, version := range accepted {
+		if major, err := Major(strings.TrimSpace(version)); err == nil {
+			majors[major] = true
+		}
+	}
+
+	for i := len(Supported) - 1; i >= 0; i-- {
+		if majors[Supported[i]] {
+			return Supported[i], nil
+		}
+	}
+	return "", fmt.Errorf("no schema version in common with workers accepting %q (supported: %s)", strings.Join(accepted, ","), strings.Join(Supported, ", "))
+}
+
+// peekVersion reads the version field of a message without decoding the rest.
+func peekVersion(data []byte) (string, error) {
+	var envelope struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return "", err
+	}
+	if envelope.Version == "" {
+		return "", fmt.Errorf("message has no schema version")
+	}
+	return envelope.Version, nil
+}
@@ -0,0 +1,243 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// golden compares got with testdata/name, or rewrites the file with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return data
+}
+
+func indent(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return append(data, '\n')
+}
+
+func testTask(version string) Task {
+	return Task{
+		Version:      version,
+		TaskID:       uuid.MustParse("6f1c2a4e-8d3b-4f5a-9c7e-1b2d3e4f5a6b"),
+		WritingID:    uuid.MustParse("0a9b8c7d-6e5f-4a3b-8c1d-2e3f4a5b6c7d"),
+		Content:      "저는 환경 보호가 중요하다고 생각합니다.",
+		WritingType:  WritingTypeEssay,
+		CallbackURL:  "http://api-server:8080/api/v1/internal/callback",
+		ResultStream: "analysis_results",
+		Attempt:      2,
+		Features:     []string{"detailed_feedback"},
+	}
+}
+
+func TestTaskGolden(t *testing.T) {
+	for _, version := range Supported {
+		t.Run("v"+version, func(t *testing.T) {
+			task := testTask(version)
+			name := "task_v" + version + ".json"
+
+			var encoded bytes.Buffer
+			if err := json.Indent(&encoded, mustMarshal(t, task), "", "  "); err != nil {
+				t.Fatalf("indent: %v", err)
+			}
+			encoded.WriteByte('\n')
+			golden(t, name, encoded.Bytes())
+
+			var decoded Task
+			if err := json.Unmarshal(readTestdata(t, name), &decoded); err != nil {
+				t.Fatalf("decode %s: %v", name, err)
+			}
+			if !reflect.DeepEqual(decoded, task) {
+				t.Fatalf("decoded %s = %+v, want %+v", name, decoded, task)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
+
+func TestTaskMinorVersion(t *testing.T) {
+	data := bytes.Replace(readTestdata(t, "task_v2.json"), []byte(`"version": "2"`), []byte(`"version": "2.1"`), 1)
+
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		t.Fatalf("decode v2.1 task: %v", err)
+	}
+	if task.Version != "2.1" || task.Content != testTask(V2).Content {
+		t.Fatalf("decoded v2.1 task = %+v", task)
+	}
+}
+
+func TestTaskUnknownMajorVersion(t *testing.T) {
+	task := testTask("3")
+	if _, err := json.Marshal(task); err == nil {
+		t.Fatal("encoded a task with unknown major version 3")
+	}
+
+	data := bytes.Replace(readTestdata(t, "task_v2.json"), []byte(`"version": "2"`), []byte(`"version": "3"`), 1)
+	var decoded Task
+	err := json.Unmarshal(data, &decoded)
+	var unsupported *UnsupportedVersionError
+	if !errors.As(err, &unsupported) || unsupported.Version != "3" {
+		t.Fatalf("decode v3 task error = %v, want UnsupportedVersionError", err)
+	}
+}
+
+func TestResultGolden(t *testing.T) {
+	for _, name := range []string{
+		"result_v1_completed.json",
+		"result_v1_failed.json",
+		"result_v2_completed.json",
+		"result_v2_processing.json",
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := DecodeResult(readTestdata(t, name))
+			if err != nil {
+				t.Fatalf("decode %s: %v", name, err)
+			}
+			// Re-encoding must reproduce the file, so nothing was dropped.
+			golden(t, name, indent(t, result))
+		})
+	}
+}
+
+func TestDecodeResultRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(map[string]interface{})
+		major3 bool
+	}{
+		{
+			name:   "unknown major version",
+			edit:   func(m map[string]interface{}) { m["version"] = "3" },
+			major3: true,
+		},
+		{
+			name: "missing version",
+			edit: func(m map[string]interface{}) { delete(m, "version") },
+		},
+		{
+			name: "malformed version",
+			edit: func(m map[string]interface{}) { m["version"] = "two" },
+		},
+		{
+			name: "v2 completed without rubric",
+			edit: func(m map[string]interface{}) {
+				delete(m["result"].(map[string]interface{}), "rubric")
+			},
+		},
+		{
+			name: "v2 completed without result",
+			edit: func(m map[string]interface{}) { delete(m, "result") },
+		},
+		{
+			name: "invalid status",
+			edit: func(m map[string]interface{}) { m["status"] = "done" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message map[string]interface{}
+			if err := json.Unmarshal(readTestdata(t, "result_v2_completed.json"), &message); err != nil {
+				t.Fatalf("decode fixture: %v", err)
+			}
+			tt.edit(message)
+
+			_, err := DecodeResult(mustMarshal(t, message))
+			if err == nil {
+				t.Fatal("DecodeResult accepted the result")
+			}
+			var unsupported *UnsupportedVersionError
+			if tt.major3 && !errors.As(err, &unsupported) {
+				t.Fatalf("error = %v, want UnsupportedVersionError", err)
+			}
+		})
+	}
+}
+
+func TestDecodeResultV1WithoutRubric(t *testing.T) {
+	result, err := DecodeResult(readTestdata(t, "result_v1_completed.json"))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if result.Output == nil || result.Output.Rubric != nil {
+		t.Fatalf("v1 result output = %+v, want no rubric", result.Output)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accepted []string
+		want     string
+		wantErr  bool
+	}{
+		{accepted: []string{"1"}, want: V1},
+		{accepted: []string{"1", "2"}, want: V2},
+		{accepted: []string{" 2.3 ", "1"}, want: V2},
+		{accepted: []string{"2", "3"}, want: V2},
+		{accepted: []string{"3"}, wantErr: true},
+		{accepted: []string{""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Negotiate(tt.accepted)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Negotiate(%q) = %q, want error", tt.accepted, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, %v, want %q", tt.accepted, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type WritingType string
+
+const (
+	WritingTypeEssay       WritingType = "essay"
+	WritingTypeCoverLetter WritingType = "cover_letter"
+)
+
+// InputTypeText is the only v2 input type so far; OCR will add image inputs.
+const InputTypeText = "text"
+
+// ScoringTOPIK54 asks v2 workers for TOPIK question 54 rubric scoring.
+const ScoringTOPIK54 = "topik54"
+
+// Task is an analysis task independent of its wire format. It is encoded in
+// the format named by Version.
+type Task struct {
+	Version     string
+	TaskID      uuid.UUID
+	WritingID   uuid.UUID
+	Content     string
+	WritingType WritingType
+	CallbackURL string
+	// ResultStream, when set, is where the worker should XADD its results
+	// instead of POSTing them to CallbackURL.
+	ResultStream string
+	Attempt      int
+	// Features lists the plan features the submitting user is entitled to,
+	// such as "detailed_feedback".
+	Features []string
+}
+
+type taskV1 struct {
+	Version      string      `json:"version"`
+	TaskID       uuid.UUID   `json:"task_id"`
+	WritingID    uuid.UUID   `json:"writing_id"`
+	Content      string      `json:"content"`
+	WritingType  WritingType `json:"writing_type"`
+	CallbackURL  string      `json:"callback_url,omitempty"`
+	ResultStream string      `json:"result_stream,omitempty"`
+	Attempt      int         `json:"attempt,omitempty"`
+	Features     []string    `json:"features,omitempty"`
+}
+
+type taskV2 struct {
+	Version      string      `json:"version"`
+	TaskID       uuid.UUID   `json:"task_id"`
+	WritingID    uuid.UUID   `json:"writing_id"`
+	WritingType  WritingType `json:"writing_type"`
+	Input        taskInput   `json:"input"`
+	Scoring      string      `json:"scoring"`
+	Features     []string    `json:"features"`
+	CallbackURL  string      `json:"callback_url,omitempty"`
+	ResultStream string      `json:"result_stream,omitempty"`
+	Attempt      int         `json:"attempt"`
+}
+
+type taskInput struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (t Task) MarshalJSON() ([]byte, error) {
+	major, err := Major(t.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case V1:
+		return json.Marshal(taskV1{
+			Version:      t.Version,
+			TaskID:       t.TaskID,
+			WritingID:    t.WritingID,
+			Content:      t.Content,
+			WritingType:  t.WritingType,
+			CallbackURL:  t.CallbackURL,
+			ResultStream: t.ResultStream,
+			Attempt:      t.Attempt,
+			Features:     t.Features,
+		})
+	default:
+		features := t.Features
+		if features == nil {
+			features = []string{}
+		}
+		return json.Marshal(taskV2{
+			Version:      t.Version,
+			TaskID:       t.TaskID,
+			WritingID:    t.WritingID,
+			WritingType:  t.WritingType,
+			Input:        taskInput{Type: InputTypeText, Text: t.Content},
+			Scoring:      ScoringTOPIK54,
+			Features:     features,
+			CallbackURL:  t.CallbackURL,
+			ResultStream: t.ResultStream,
+			Attempt:      t.Attempt,
+		})
+	}
+}
+
+func (t *Task) UnmarshalJSON(data []byte) error {
+	version, err := peekVersion(data)
+	if err != nil {
+		return err
+	}
+	major, err := Major(version)
+	if err != nil {
+		return err
+	}
+
+	switch major {
+	case V1:
+		var wire taskV1
+		if err := json.Unmarshal(data, &wire); err != nil {
+			return err
+		}
+		*t = Task{
+			Version:      wire.Version,
+			TaskID:       wire.TaskID,
+			WritingID:    wire.WritingID,
+			Content:      wire.Content,
+			WritingType:  wire.WritingType,
+			CallbackURL:  wire.CallbackURL,
+			ResultStream: wire.ResultStream,
+			Attempt:      wire.Attempt,
+			Features:     wire.Features,
+		}
+	default:
+		var wire taskV2
+		if err := json.Unmarshal(data, &wire); err != nil {
+			return err
+		}
+		if wire.Input.Type != InputTypeText {
+			return fmt.Errorf("unsupported task input type %q", wire.Input.Type)
+		}
+		*t = Task{
+			Version:      wire.Version,
+			TaskID:       wire.TaskID,
+			WritingID:    wire.WritingID,
+			Content:      wire.Input.Text,
+			WritingType:  wire.WritingType,
+			CallbackURL:  wire.CallbackURL,
+			ResultStream: wire.ResultStream,
+			Attempt:      wire.Attempt,
+			Features:     wire.Features,
+		}
+	}
+	return nil
+}
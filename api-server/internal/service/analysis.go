@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,36 +11,48 @@ import (
 	"github.com/truegul/api-server/internal/config"
 	"github.com/truegul/api-server/internal/data"
 	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/events"
 	"github.com/truegul/api-server/internal/model"
 	"github.com/truegul/api-server/internal/mq"
 	"github.com/truegul/api-server/internal/repository"
 )
 
 const (
-	MaxDailySubmissions = 5
 	MaxRetries          = 3
+	RetryBaseDelay      = 10 * time.Second
+	RetryMaxDelay       = 5 * time.Minute
+	UnknownModelVersion = "unknown"
 )
 
 type AnalysisService struct {
+	transactor   *repository.Transactor
 	analysisRepo *repository.AnalysisRepository
 	writingRepo  *repository.WritingRepository
 	userRepo     *repository.UserRepository
+	planRepo     *repository.PlanRepository
 	publisher    mq.Publisher
+	broker       events.Broker
 	config       *config.Config
 }
 
 func NewAnalysisService(
+	transactor *repository.Transactor,
 	analysisRepo *repository.AnalysisRepository,
 	writingRepo *repository.WritingRepository,
 	userRepo *repository.UserRepository,
+	planRepo *repository.PlanRepository,
 	publisher mq.Publisher,
+	broker events.Broker,
 	cfg *config.Config,
 ) *AnalysisService {
 	return &AnalysisService{
+		transactor:   transactor,
 		analysisRepo: analysisRepo,
 		writingRepo:  writingRepo,
 		userRepo:     userRepo,
+		planRepo:     planRepo,
 		publisher:    publisher,
+		broker:       broker,
 		config:       cfg,
 	}
 }
@@ -54,26 +67,19 @@ func (s *AnalysisService) SubmitWriting(ctx context.Context, writingID, userID u
 		return nil, apperrors.Forbidden("You don't have permission to submit this writing")
 	}
 
-	if writing.Status != data.WritingStatusDraft {
-		return nil, apperrors.Validation("Writing has already been submitted")
+	switch writing.Status {
+	case data.WritingStatusSubmitted:
+		return nil, apperrors.InvalidTransition("Writing has already been submitted")
+	case data.WritingStatusAnalyzed:
+		return nil, apperrors.InvalidTransition("Writing has already been analyzed; edit it to submit a new revision")
 	}
-
-	user, err := s.userRepo.FindByID(userID)
-	if err != nil {
+	if err := transitionWriting(writing, data.WritingStatusSubmitted); err != nil {
 		return nil, err
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
-	if user.LastSubmitDate == nil || user.LastSubmitDate.Truncate(24*time.Hour).Before(today) {
-		user.DailySubmitCount = 0
-	}
-
-	if user.DailySubmitCount >= MaxDailySubmissions {
-		return nil, apperrors.New(
-			apperrors.CodeForbidden,
-			fmt.Sprintf("Daily submission limit reached (%d/%d)", user.DailySubmitCount, MaxDailySubmissions),
-			429,
-		)
+	plan, err := s.planRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
 	}
 
 	taskID := uuid.New()
@@ -83,117 +89,656 @@ func (s *AnalysisService) SubmitWriting(ctx context.Context, writingID, userID u
 		Status:    model.AnalysisStatusPending,
 	}
 
-	if err := s.analysisRepo.Create(analysis); err != nil {
+	// The task is handed to the outbox relay in the same transaction as the
+	// state changes so a queue outage can never strand a submitted writing.
+	err = s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+		// Taking the quota first holds the user's row lock for the rest of the
+		// transaction, and a later failure rolls the submission back.
+		if _, ok, err := tx.Users.ConsumeSubmission(userID, plan.DailySubmissionLimit, plan.MonthlySubmissionLimit); err != nil {
+			return err
+		} else if !ok {
+			return quotaExceeded(tx.Users, userID, plan)
+		}
+
+		// The status checks above ran on a copy read outside the transaction;
+		// of two concurrent submits of the same draft only one moves it.
+		submitted, err := tx.Writings.TransitionStatus(writingID, data.WritingStatusDraft, data.WritingStatusSubmitted)
+		if err != nil {
+			return err
+		}
+		if !submitted {
+			return apperrors.InvalidTransition("Writing has already been submitted")
+		}
+
+		revision, err := tx.Writings.CreateRevision(writingID)
+		if err != nil {
+			return err
+		}
+
+		// The plan may have been downgraded since the writing was saved.
+		if err := checkContentLength(plan, revision.Content); err != nil {
+			return err
+		}
+
+		contentHash := hashContent(revision.Content)
+		analysis.RevisionID = &revision.ID
+		analysis.ContentHash = &contentHash
+		if err := tx.Analyses.Create(analysis); err != nil {
+			return err
+		}
+
+		return tx.Outbox.Enqueue(model.OutboxKindAnalysisTask, s.buildTask(taskID, revision, plan))
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	now := time.Now()
-	writing.Status = data.WritingStatusSubmitted
-	writing.SubmittedAt = &now
-	if err := s.writingRepo.Update(writing); err != nil {
+	return analysis, nil
+}
+
+// CancelAnalysis withdraws the writing's in-flight analysis: the analysis is
+// marked cancelled, the workers are told to drop the task, the writing goes
+// back to draft and the submission is refunded if it counted towards today's
+// quota.
+func (s *AnalysisService) CancelAnalysis(ctx context.Context, writingID, userID uuid.UUID) (*model.Analysis, error) {
+	writing, err := s.writingRepo.FindByID(writingID)
+	if err != nil {
 		return nil, err
 	}
 
-	user.DailySubmitCount++
-	user.LastSubmitDate = &now
-	if err := s.userRepo.Update(user); err != nil {
-		return nil, err
+	if writing.UserID != userID {
+		return nil, apperrors.Forbidden("You don't have permission to cancel this analysis")
 	}
 
-	callbackURL := fmt.Sprintf("%s%s", s.config.CallbackBaseURL, s.config.CallbackPath)
-	task := mq.AnalysisTask{
-		Version:     "1",
-		TaskID:      taskID,
-		WritingID:   writingID,
-		Content:     writing.Content,
-		WritingType: mq.WritingType(writing.Type),
-		CallbackURL: callbackURL,
+	analysis, err := s.analysisRepo.FindByWritingID(writingID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.Status.IsTerminal() || analysis.TaskID == nil {
+		return nil, apperrors.InvalidTransition(fmt.Sprintf("Analysis is already %s", analysis.Status))
 	}
 
-	if err := s.publisher.Publish(ctx, task); err != nil {
-		return nil, apperrors.InternalServerWrap(err, "Failed to queue analysis task")
+	taskID := *analysis.TaskID
+	err = s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+		cancelled, err := tx.Analyses.Cancel(taskID)
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			return apperrors.InvalidTransition("Analysis has already finished")
+		}
+
+		// Only the status changes, and only if the writing is still the one
+		// under analysis; anything else about it is the owner's to edit.
+		if _, err := tx.Writings.TransitionStatus(writingID, data.WritingStatusSubmitted, data.WritingStatusDraft); err != nil {
+			return err
+		}
+
+		if err := tx.Users.RefundSubmission(userID, analysis.CreatedAt); err != nil {
+			return err
+		}
+
+		return tx.Outbox.Enqueue(model.OutboxKindAnalysisCancel, mq.CancelTask{
+			TaskID:      taskID,
+			WritingID:   writingID,
+			RequestedAt: time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return analysis, nil
+	s.notify(ctx, taskID)
+	return s.analysisRepo.FindByTaskID(taskID)
 }
 
 func (s *AnalysisService) GetAnalysis(writingID, userID uuid.UUID) (*model.Analysis, error) {
-	writing, err := s.writingRepo.FindByID(writingID)
-	if err != nil {
+	if _, err := s.ownedWriting(writingID, userID); err != nil {
 		return nil, err
 	}
 
-	if writing.UserID != userID {
-		return nil, apperrors.Forbidden("You don't have permission to view this analysis")
+	return s.analysisRepo.FindByWritingID(writingID)
+}
+
+func (s *AnalysisService) ListAnalyses(writingID, userID uuid.UUID, page, limit int) ([]model.Analysis, int64, error) {
+	if _, err := s.ownedWriting(writingID, userID); err != nil {
+		return nil, 0, err
 	}
 
-	return s.analysisRepo.FindByWritingID(writingID)
+	offset := (page - 1) * limit
+	return s.analysisRepo.FindAllByWritingID(writingID, offset, limit)
 }
 
-func (s *AnalysisService) HandleCallback(taskID uuid.UUID, status string, result *CallbackResult, callbackErr *CallbackError) error {
-	analysis, err := s.analysisRepo.FindByTaskID(taskID)
+// SubscribeAnalysis returns the writing's latest analysis, if any, together
+// with a subscription to its subsequent state changes.
+func (s *AnalysisService) SubscribeAnalysis(ctx context.Context, writingID, userID uuid.UUID) (*model.Analysis, *events.Subscription, error) {
+	if _, err := s.ownedWriting(writingID, userID); err != nil {
+		return nil, nil, err
+	}
+
+	// Subscribe before reading the current state so that a transition landing
+	// in between is delivered rather than lost.
+	sub, err := s.broker.SubscribeAnalysis(ctx, writingID)
+	if err != nil {
+		return nil, nil, apperrors.InternalServerWrap(err, "Failed to subscribe to analysis events")
+	}
+
+	analysis, err := s.analysisRepo.FindByWritingID(writingID)
+	if err != nil {
+		if appErr, ok := apperrors.IsAppError(err); ok && appErr.Code == apperrors.CodeNotFound {
+			return nil, sub, nil
+		}
+		_ = sub.Close()
+		return nil, nil, err
+	}
+
+	return analysis, sub, nil
+}
+
+func (s *AnalysisService) HandleCallback(ctx context.Context, cb *Callback) error {
+	analysis, err := s.analysisRepo.FindByTaskID(cb.TaskID)
 	if err != nil {
 		return err
 	}
 
-	if analysis.Status == model.AnalysisStatusCompleted || analysis.Status == model.AnalysisStatusFailed {
-		return nil
+	if err := s.applyCallback(ctx, analysis, cb, s.callbackLog(analysis, cb)); err != nil {
+		return err
+	}
+	s.notify(ctx, cb.TaskID)
+	return nil
+}
+
+func (s *AnalysisService) ListLogs(filter repository.AnalysisLogFilter, page, limit int) ([]model.AnalysisLog, int64, error) {
+	offset := (page - 1) * limit
+	return s.analysisRepo.FindLogs(filter, offset, limit)
+}
+
+// callbackLog builds the audit trail entry for a callback: the revision that
+// was analyzed, the model that produced the result and the raw payload as
+// sent. applyCallback writes it together with the state change.
+func (s *AnalysisService) callbackLog(analysis *model.Analysis, cb *Callback) *model.AnalysisLog {
+	modelVersion := cb.ModelVersion
+	if modelVersion == "" && cb.Result != nil && cb.Result.AIDetection != nil {
+		modelVersion = cb.Result.AIDetection.ModelVersion
+	}
+	if modelVersion == "" {
+		modelVersion = UnknownModelVersion
+	}
+
+	var rawOutput *string
+	if len(cb.RawPayload) > 0 {
+		raw := string(cb.RawPayload)
+		rawOutput = &raw
+	}
+
+	entry := &model.AnalysisLog{
+		AnalysisID:   analysis.ID,
+		RevisionID:   analysis.RevisionID,
+		ModelVersion: modelVersion,
+		RawOutput:    rawOutput,
+	}
+	if analysis.RevisionID == nil {
+		// Analyses from before revisions have no snapshot to point at.
+		if writing, err := s.writingRepo.FindByID(analysis.WritingID); err == nil {
+			entry.InputText = &writing.Content
+		}
+	}
+	return entry
+}
+
+func (s *AnalysisService) applyCallback(ctx context.Context, analysis *model.Analysis, cb *Callback, entry *model.AnalysisLog) error {
+	taskID := cb.TaskID
+	status := cb.Status
+	progress := cb.Progress
+	result := cb.Result
+	callbackErr := cb.Error
+
+	// Late results for finished or cancelled tasks are logged but otherwise
+	// ignored.
+	if analysis.Status.IsTerminal() {
+		return s.analysisRepo.CreateLog(entry)
+	}
+
+	if status == "processing" {
+		var stage *model.AnalysisStage
+		var percent *int
+		if progress != nil {
+			if progress.Stage != "" {
+				st := model.AnalysisStage(progress.Stage)
+				stage = &st
+			}
+			percent = progress.Percent
+		}
+		return s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+			if err := tx.Analyses.CreateLog(entry); err != nil {
+				return err
+			}
+			return tx.Analyses.UpdateProgress(taskID, stage, percent)
+		})
 	}
 
 	if status == "completed" && result != nil {
+		if err := validateRubric(result.Rubric); err != nil {
+			if logErr := s.analysisRepo.CreateLog(entry); logErr != nil {
+				return logErr
+			}
+			return err
+		}
+
 		aiScore := result.AIProbability
 		feedback := result.Feedback
 		latencyMs := result.LatencyMs
 
-		if err := s.analysisRepo.UpdateResult(
-			taskID,
-			model.AnalysisStatusCompleted,
-			&aiScore,
-			&feedback,
-			nil,
-			nil,
-			&latencyMs,
-		); err != nil {
-			return err
+		return s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+			if err := tx.Analyses.CreateLog(entry); err != nil {
+				return err
+			}
+			// A cancel or another result may have landed since the analysis
+			// was read; the callback is then only logged.
+			updated, err := tx.Analyses.UpdateResult(
+				taskID,
+				model.AnalysisStatusCompleted,
+				&aiScore,
+				&feedback,
+				nil,
+				nil,
+				&latencyMs,
+			)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return nil
+			}
+
+			if result.AIDetection != nil {
+				if err := tx.Analyses.CreateAIDetection(s.toDetectionModel(analysis.ID, result.AIDetection)); err != nil {
+					return err
+				}
+			}
+
+			if result.Rubric != nil {
+				if err := tx.Analyses.CreateScore(toScoreModel(analysis.ID, cb.Version, result.Rubric)); err != nil {
+					return err
+				}
+			}
+
+			analyzed, err := tx.Writings.TransitionStatus(analysis.WritingID, data.WritingStatusSubmitted, data.WritingStatusAnalyzed)
+			if err != nil {
+				return err
+			}
+			if !analyzed {
+				return apperrors.InvalidTransition("Writing is no longer awaiting this analysis")
+			}
+			return nil
+		})
+	}
+
+	if status == "failed" && callbackErr != nil {
+		if callbackErr.Retryable {
+			return s.retry(ctx, analysis, callbackErr, entry)
 		}
 
-		writing, err := s.writingRepo.FindByID(analysis.WritingID)
-		if err != nil {
+		return s.fail(analysis, callbackErr, model.AnalysisErrorCode(callbackErr.Code), callbackErr.Message, entry)
+	}
+
+	if err := s.analysisRepo.CreateLog(entry); err != nil {
+		return err
+	}
+	return apperrors.Validation("Invalid callback status")
+}
+
+// Expire handles an in-flight analysis that outlived its SLA. When requeue is
+// set the task goes back through the retry policy; otherwise, or once retries
+// are spent, the analysis fails with AnalysisErrorCodeTimeout.
+func (s *AnalysisService) Expire(ctx context.Context, analysis *model.Analysis, requeue bool) error {
+	if err := s.expire(ctx, analysis, requeue); err != nil {
+		return err
+	}
+	s.notify(ctx, *analysis.TaskID)
+	return nil
+}
+
+// FailUndeliverable fails an in-flight analysis whose task could not be
+// handed to the message queue and refunds its submission, since the failure
+// was not the user's doing.
+func (s *AnalysisService) FailUndeliverable(ctx context.Context, taskID uuid.UUID) error {
+	return s.failRefunded(ctx, taskID, &CallbackError{
+		Code:    string(model.AnalysisErrorCodeUndeliverable),
+		Message: "The analysis task could not be queued",
+	})
+}
+
+// FailUnapplied fails an in-flight analysis whose result the api-server kept
+// failing to apply, and refunds its submission.
+func (s *AnalysisService) FailUnapplied(ctx context.Context, taskID uuid.UUID) error {
+	return s.failRefunded(ctx, taskID, &CallbackError{
+		Code:    string(model.AnalysisErrorCodeInternal),
+		Message: "The analysis result could not be saved",
+	})
+}
+
+// failRefunded fails the analysis of taskID with cause and refunds the
+// submission when this call is what failed it.
+func (s *AnalysisService) failRefunded(ctx context.Context, taskID uuid.UUID, cause *CallbackError) error {
+	analysis, err := s.analysisRepo.FindByTaskID(taskID)
+	if err != nil {
+		return err
+	}
+	if analysis.Status.IsTerminal() {
+		return nil
+	}
+
+	writing, err := s.writingRepo.FindByID(analysis.WritingID)
+	if err != nil {
+		return err
+	}
+
+	err = s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+		failed, err := s.failIn(tx, analysis, cause, model.AnalysisErrorCode(cause.Code), cause.Message, nil)
+		if err != nil || !failed {
 			return err
 		}
-		writing.Status = data.WritingStatusAnalyzed
-		return s.writingRepo.Update(writing)
+		return tx.Users.RefundSubmission(writing.UserID, analysis.CreatedAt)
+	})
+	if err != nil {
+		return err
 	}
+	s.notify(ctx, taskID)
+	return nil
+}
 
-	if status == "failed" && callbackErr != nil {
-		errorCode := model.AnalysisErrorCode(callbackErr.Code)
-		errorMessage := callbackErr.Message
+func (s *AnalysisService) expire(ctx context.Context, analysis *model.Analysis, requeue bool) error {
+	cause := &CallbackError{
+		Code:      string(model.AnalysisErrorCodeTimeout),
+		Message:   "No result received from the analysis worker in time",
+		Retryable: requeue,
+	}
+
+	if requeue && analysis.RetryCount < MaxRetries {
+		return s.retry(ctx, analysis, cause, nil)
+	}
+
+	return s.fail(analysis, cause, model.AnalysisErrorCodeTimeout, cause.Message, nil)
+}
 
-		if callbackErr.Retryable && analysis.RetryCount < MaxRetries {
-			if err := s.analysisRepo.IncrementRetryCount(taskID); err != nil {
+// retry re-enqueues the analysis task with exponential backoff, or marks the
+// analysis failed once MaxRetries re-deliveries have been spent. A non-nil
+// entry is logged in the same transaction.
+func (s *AnalysisService) retry(ctx context.Context, analysis *model.Analysis, callbackErr *CallbackError, entry *model.AnalysisLog) error {
+	if analysis.RetryCount >= MaxRetries {
+		errorMessage := fmt.Sprintf("Analysis failed after %d attempts: %s", analysis.RetryCount+1, callbackErr.Message)
+		return s.fail(analysis, callbackErr, model.AnalysisErrorCodeExhausted, errorMessage, entry)
+	}
+
+	revision, err := s.scoredRevision(analysis)
+	if err != nil {
+		return err
+	}
+
+	writing, err := s.writingRepo.FindByID(analysis.WritingID)
+	if err != nil {
+		return err
+	}
+	plan, err := s.planRepo.FindByUserID(writing.UserID)
+	if err != nil {
+		return err
+	}
+
+	nextRetryAt := time.Now().Add(retryBackoff(analysis.RetryCount))
+	task := s.buildTask(*analysis.TaskID, revision, plan)
+	task.Attempt = analysis.RetryCount + 2
+
+	// Claiming the retry, recording the attempt and scheduling the delivery
+	// commit together, so a duplicate callback cannot schedule a second
+	// delivery and a scheduled delivery always counts towards MaxRetries.
+	return s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+		if entry != nil {
+			if err := tx.Analyses.CreateLog(entry); err != nil {
 				return err
 			}
+		}
+
+		claimed, err := tx.Analyses.IncrementRetryCount(*analysis.TaskID, analysis.RetryCount)
+		if err != nil {
+			return err
+		}
+		if !claimed {
 			return nil
 		}
 
-		return s.analysisRepo.UpdateResult(
-			taskID,
-			model.AnalysisStatusFailed,
-			nil,
-			nil,
-			&errorCode,
-			&errorMessage,
-			nil,
+		if err := tx.Analyses.CreateAttempt(newAttempt(analysis, callbackErr, &nextRetryAt)); err != nil {
+			return err
+		}
+
+		return tx.Outbox.Enqueue(model.OutboxKindAnalysisRetry, mq.ScheduledTask{Task: task, At: nextRetryAt})
+	})
+}
+
+// fail records the final attempt, marks the analysis failed and hands the
+// writing back to its owner as a draft so it can be corrected and resubmitted.
+// A non-nil entry is logged in the same transaction.
+func (s *AnalysisService) fail(analysis *model.Analysis, cause *CallbackError, errorCode model.AnalysisErrorCode, errorMessage string, entry *model.AnalysisLog) error {
+	return s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+		_, err := s.failIn(tx, analysis, cause, errorCode, errorMessage, entry)
+		return err
+	})
+}
+
+// failIn is fail within tx. It reports false when the analysis had already
+// settled, in which case nothing but the log entry is written.
+func (s *AnalysisService) failIn(tx *repository.Tx, analysis *model.Analysis, cause *CallbackError, errorCode model.AnalysisErrorCode, errorMessage string, entry *model.AnalysisLog) (bool, error) {
+	if entry != nil {
+		if err := tx.Analyses.CreateLog(entry); err != nil {
+			return false, err
+		}
+	}
+
+	updated, err := tx.Analyses.UpdateResult(
+		*analysis.TaskID,
+		model.AnalysisStatusFailed,
+		nil,
+		nil,
+		&errorCode,
+		&errorMessage,
+		nil,
+	)
+	if err != nil || !updated {
+		return false, err
+	}
+
+	if err := tx.Analyses.CreateAttempt(newAttempt(analysis, cause, nil)); err != nil {
+		return false, err
+	}
+
+	// A writing that is no longer submitted has nothing to hand back.
+	if _, err := tx.Writings.TransitionStatus(analysis.WritingID, data.WritingStatusSubmitted, data.WritingStatusDraft); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func newAttempt(analysis *model.Analysis, cause *CallbackError, nextRetryAt *time.Time) *model.AnalysisAttempt {
+	errorCode := model.AnalysisErrorCode(cause.Code)
+	errorMessage := cause.Message
+
+	return &model.AnalysisAttempt{
+		AnalysisID:   analysis.ID,
+		Attempt:      analysis.RetryCount + 1,
+		ErrorCode:    &errorCode,
+		ErrorMessage: &errorMessage,
+		Retryable:    cause.Retryable,
+		NextRetryAt:  nextRetryAt,
+	}
+}
+
+func (s *AnalysisService) ownedWriting(writingID, userID uuid.UUID) (*data.Writing, error) {
+	writing, err := s.writingRepo.FindByID(writingID)
+	if err != nil {
+		return nil, err
+	}
+
+	if writing.UserID != userID {
+		return nil, apperrors.Forbidden("You don't have permission to view this analysis")
+	}
+
+	return writing, nil
+}
+
+func (s *AnalysisService) analysisOfWriting(analysisID, writingID uuid.UUID) (*model.Analysis, error) {
+	analysis, err := s.analysisRepo.FindByID(analysisID)
+	if err != nil {
+		return nil, err
+	}
+
+	if analysis.WritingID != writingID {
+		return nil, apperrors.NotFound("Analysis not found")
+	}
+
+	return analysis, nil
+}
+
+// notify broadcasts the analysis' current state to event subscribers. It is
+// best effort: subscribers can always fall back to polling.
+func (s *AnalysisService) notify(ctx context.Context, taskID uuid.UUID) {
+	analysis, err := s.analysisRepo.FindByTaskID(taskID)
+	if err != nil {
+		log.Printf("Failed to load analysis for task %s event: %v", taskID, err)
+		return
+	}
+	if err := s.broker.PublishAnalysis(ctx, analysis); err != nil {
+		log.Printf("Failed to publish analysis %s event: %v", analysis.ID, err)
+	}
+}
+
+// toDetectionModel flags the detection against the configured threshold
+// rather than trusting a flag computed by the worker, so the threshold can be
+// tuned without redeploying the ML server.
+func (s *AnalysisService) toDetectionModel(analysisID uuid.UUID, detection *CallbackAIDetection) *model.AIDetection {
+	detectedAt := time.Now()
+	if detection.DetectedAt != nil {
+		detectedAt = *detection.DetectedAt
+	}
+
+	return &model.AIDetection{
+		AnalysisID:   analysisID,
+		Score:        detection.Score,
+		IsFlagged:    detection.Score >= s.config.AIDetectionThreshold,
+		Threshold:    s.config.AIDetectionThreshold,
+		ModelVersion: detection.ModelVersion,
+		DetectedAt:   detectedAt,
+	}
+}
+
+// scoredRevision returns the revision an analysis scored. Analyses created
+// before revisions existed fall back to the writing's current content.
+func (s *AnalysisService) scoredRevision(analysis *model.Analysis) (*data.WritingRevision, error) {
+	if analysis.RevisionID != nil {
+		return s.writingRepo.FindRevisionByID(*analysis.RevisionID)
+	}
+
+	writing, err := s.writingRepo.FindByID(analysis.WritingID)
+	if err != nil {
+		return nil, err
+	}
+	return &data.WritingRevision{
+		WritingID: writing.ID,
+		Type:      writing.Type,
+		Title:     writing.Title,
+		Content:   writing.Content,
+	}, nil
+}
+
+// quotaExceeded builds the error for a submission refused by
+// ConsumeSubmission, naming whichever of the plan's limits was hit and when it
+// resets.
+func quotaExceeded(users *repository.UserRepository, userID uuid.UUID, plan *data.Plan) error {
+	user, err := users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	usage, err := users.SubmissionUsage(userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	dayEnd, monthEnd := quotaResets(now, user.Timezone)
+	if usage.Monthly >= plan.MonthlySubmissionLimit {
+		return apperrors.RateLimited(
+			fmt.Sprintf("Monthly submission limit reached (%d/%d)", usage.Monthly, plan.MonthlySubmissionLimit),
+			monthEnd.Sub(now),
 		)
 	}
+	return apperrors.RateLimited(
+		fmt.Sprintf("Daily submission limit reached (%d/%d)", usage.Daily, plan.DailySubmissionLimit),
+		dayEnd.Sub(now),
+	)
+}
 
-	return apperrors.Validation("Invalid callback status")
+func (s *AnalysisService) buildTask(taskID uuid.UUID, revision *data.WritingRevision, plan *data.Plan) mq.AnalysisTask {
+	features := make([]string, len(plan.Features))
+	for i, f := range plan.Features {
+		features[i] = string(f)
+	}
+
+	task := mq.AnalysisTask{
+		Version:     s.config.TaskSchemaVersion,
+		TaskID:      taskID,
+		WritingID:   revision.WritingID,
+		Content:     revision.Content,
+		WritingType: mq.WritingType(revision.Type),
+		Attempt:     1,
+		Features:    features,
+	}
+	if s.config.CallbackHTTPEnabled {
+		task.CallbackURL = fmt.Sprintf("%s%s", s.config.CallbackBaseURL, s.config.CallbackPath)
+	}
+	if s.config.ResultsConsumerInterval > 0 {
+		task.ResultStream = s.config.ResultsStream
+	}
+	return task
+}
+
+// retryBackoff doubles RetryBaseDelay for every retry already spent, capped
+// at RetryMaxDelay.
+func retryBackoff(retryCount int) time.Duration {
+	delay := RetryBaseDelay << retryCount
+	if delay <= 0 || delay > RetryMaxDelay {
+		return RetryMaxDelay
+	}
+	return delay
+}
+
+// Callback is a status report from the ML worker about one analysis task.
+type Callback struct {
+	TaskID       uuid.UUID
+	Version      string
+	Status       string
+	ModelVersion string
+	Progress     *CallbackProgress
+	Result       *CallbackResult
+	Error        *CallbackError
+	RawPayload   []byte
+}
+
+type CallbackProgress struct {
+	Stage   string
+	Percent *int
 }
 
 type CallbackResult struct {
 	AIProbability float64
 	Feedback      string
 	LatencyMs     int
+	Rubric        *CallbackRubric
+	AIDetection   *CallbackAIDetection
+}
+
+type CallbackAIDetection struct {
+	Score        float64
+	ModelVersion string
+	DetectedAt   *time.Time
 }
 
 type CallbackError struct {
@@ -1,22 +1,43 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/truegul/api-server/internal/data"
 	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/loginguard"
+	"github.com/truegul/api-server/internal/model"
 	"github.com/truegul/api-server/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// DefaultTimezone defines the submission day for users who never chose a
+// timezone.
+const DefaultTimezone = "UTC"
+
+// invalidCredentials is the single response for every failed login, whether
+// the email is unknown or the password wrong.
+const invalidCredentials = "Invalid email or password"
+
+// dummyPasswordHash is compared against when the email is unknown so that a
+// failed login takes as long whether or not the account exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("truegul-dummy-password"), bcrypt.DefaultCost)
+
 type AuthService struct {
-	userRepo  *repository.UserRepository
-	jwtSecret []byte
-	jwtExpiry time.Duration
+	transactor  *repository.Transactor
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	lockoutRepo *repository.LoginLockoutRepository
+	loginGuard  *loginguard.Guard
+	jwtSecret   []byte
+	jwtExpiry   time.Duration
+	refreshTTL  time.Duration
 }
 
 type JWTClaims struct {
@@ -25,15 +46,29 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtSecret string, jwtExpiry time.Duration) *AuthService {
+func NewAuthService(
+	transactor *repository.Transactor,
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	lockoutRepo *repository.LoginLockoutRepository,
+	loginGuard *loginguard.Guard,
+	jwtSecret string,
+	jwtExpiry time.Duration,
+	refreshTTL time.Duration,
+) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: []byte(jwtSecret),
-		jwtExpiry: jwtExpiry,
+		transactor:  transactor,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		lockoutRepo: lockoutRepo,
+		loginGuard:  loginGuard,
+		jwtSecret:   []byte(jwtSecret),
+		jwtExpiry:   jwtExpiry,
+		refreshTTL:  refreshTTL,
 	}
 }
 
-func (s *AuthService) Signup(email, password string) (*data.User, error) {
+func (s *AuthService) Signup(email, password, timezone string) (*data.User, error) {
 	exists, err := s.userRepo.ExistsByEmail(email)
 	if err != nil {
 		return nil, err
@@ -50,6 +85,12 @@ func (s *AuthService) Signup(email, password string) (*data.User, error) {
 	user := &data.User{
 		Email:        email,
 		PasswordHash: string(hashedPassword),
+		Role:         data.UserRoleUser,
+		Timezone:     timezone,
+		PlanCode:     data.DefaultPlanCode,
+	}
+	if user.Timezone == "" {
+		user.Timezone = DefaultTimezone
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
@@ -59,30 +100,75 @@ func (s *AuthService) Signup(email, password string) (*data.User, error) {
 	return user, nil
 }
 
-func (s *AuthService) Login(email, password string) (*data.User, string, error) {
-	user, err := s.userRepo.FindByEmail(email)
+// Login checks the credentials, throttling repeated failures per account and
+// per client IP, and starts a new session for the client. Throttled and
+// locked-out attempts are refused before the password is checked, with the
+// same response whether or not the account exists.
+func (s *AuthService) Login(ctx context.Context, email, password string, client ClientInfo) (*data.User, *AuthTokens, error) {
+	wait, err := s.loginGuard.Check(ctx, email, client.IPAddress)
 	if err != nil {
-		return nil, "", apperrors.Unauthorized("Invalid email or password")
+		log.Printf("Login guard unavailable: %v", err)
+	}
+	if wait > 0 {
+		return nil, nil, apperrors.RateLimited("Too many failed login attempts, please try again later", wait)
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	passwordHash := dummyPasswordHash
+	if err == nil {
+		passwordHash = []byte(user.PasswordHash)
+	}
+
+	if bcrypt.CompareHashAndPassword(passwordHash, []byte(password)) != nil || err != nil {
+		s.recordFailure(ctx, email, client.IPAddress)
+		return nil, nil, apperrors.Unauthorized(invalidCredentials)
+	}
+
+	if err := s.loginGuard.Reset(ctx, email); err != nil {
+		log.Printf("Failed to reset login guard: %v", err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, "", apperrors.Unauthorized("Invalid email or password")
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	token, err := s.GenerateToken(user)
+	return user, tokens, nil
+}
+
+// recordFailure counts a failed login and audits any lockout it triggers.
+// Errors are logged rather than returned so the caller's response stays the
+// same.
+func (s *AuthService) recordFailure(ctx context.Context, email, ip string) {
+	lockouts, err := s.loginGuard.RecordFailure(ctx, email, ip)
 	if err != nil {
-		return nil, "", apperrors.InternalServerWrap(err, "Failed to generate token")
+		log.Printf("Failed to record login failure: %v", err)
 	}
 
-	return user, token, nil
+	normalized := loginguard.NormalizeEmail(email)
+	for _, lockout := range lockouts {
+		log.Printf("Login locked out (%s) for %s from %s until %s", lockout.Scope, normalized, ip, lockout.LockedUntil.Format(time.RFC3339))
+		if err := s.lockoutRepo.Create(&model.LoginLockout{
+			Scope:       string(lockout.Scope),
+			Email:       &normalized,
+			IPAddress:   ip,
+			Failures:    lockout.Failures,
+			LockedUntil: lockout.LockedUntil,
+		}); err != nil {
+			log.Printf("Failed to audit login lockout: %v", err)
+		}
+	}
 }
 
-func (s *AuthService) GenerateToken(user *data.User) (string, error) {
+// GenerateToken issues an access token for the session. The session ID is the
+// token's jti, so revoking the session revokes the token.
+func (s *AuthService) GenerateToken(user *data.User, sessionID uuid.UUID, expiresAt time.Time) (string, error) {
 	claims := JWTClaims{
 		UserID: user.ID,
 		Email:  user.Email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
+			ID:        sessionID.String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   user.ID.String(),
 		},
@@ -108,17 +194,29 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, apperrors.Unauthorized("Invalid or expired token")
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, apperrors.Unauthorized("Invalid token")
 	}
 
-	return nil, apperrors.Unauthorized("Invalid token")
+	if err := s.checkSession(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
 }
 
 func (s *AuthService) GetUserByID(id uuid.UUID) (*data.User, error) {
 	return s.userRepo.FindByID(id)
 }
 
+func (s *AuthService) UpdateTimezone(id uuid.UUID, timezone string) (*data.User, error) {
+	if err := s.userRepo.UpdateTimezone(id, timezone); err != nil {
+		return nil, err
+	}
+	return s.userRepo.FindByID(id)
+}
+
 func GenerateCSRFToken() (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/schema"
+)
+
+// DecodeCallback parses and validates a result as the ML server sends it,
+// whether it arrived on the callback endpoint or on the results stream. The
+// payload is kept verbatim for the analysis log.
+func DecodeCallback(raw []byte) (*Callback, error) {
+	result, err := schema.DecodeResult(raw)
+	if err != nil {
+		var unsupported *schema.UnsupportedVersionError
+		if errors.As(err, &unsupported) {
+			return nil, apperrors.Validation(fmt.Sprintf("Unsupported callback schema version %q; supported major versions: %s",
+				unsupported.Version, strings.Join(schema.Supported, ", ")))
+		}
+		return nil, apperrors.Validation(err.Error())
+	}
+
+	taskID, err := uuid.Parse(result.TaskID)
+	if err != nil {
+		return nil, apperrors.Validation("Invalid task ID")
+	}
+
+	return toCallback(taskID, result, raw), nil
+}
+
+func toCallback(taskID uuid.UUID, result *schema.Result, raw []byte) *Callback {
+	cb := &Callback{
+		TaskID:       taskID,
+		Version:      result.Version,
+		Status:       result.Status,
+		ModelVersion: result.ModelVersion,
+		RawPayload:   raw,
+	}
+
+	if result.Progress != nil {
+		cb.Progress = &CallbackProgress{
+			Stage:   result.Progress.Stage,
+			Percent: result.Progress.Percent,
+		}
+	}
+
+	if output := result.Output; output != nil {
+		cb.Result = &CallbackResult{
+			AIProbability: output.AIProbability,
+			Feedback:      output.Feedback,
+			LatencyMs:     output.LatencyMs,
+		}
+		if detection := output.AIDetection; detection != nil {
+			cb.Result.AIDetection = &CallbackAIDetection{
+				Score:        detection.Score,
+				ModelVersion: detection.ModelVersion,
+				DetectedAt:   detection.DetectedAt,
+			}
+		}
+		if rubric := output.Rubric; rubric != nil {
+			cb.Result.Rubric = &CallbackRubric{
+				Content:       toCallbackCriterion(rubric.Content),
+				Structure:     toCallbackCriterion(rubric.Structure),
+				Language:      toCallbackCriterion(rubric.Language),
+				Total:         rubric.Total,
+				LevelEstimate: rubric.LevelEstimate,
+				Suggestions:   rubric.Suggestions,
+			}
+		}
+	}
+
+	if result.Error != nil {
+		cb.Error = &CallbackError{
+			Code:      result.Error.Code,
+			Message:   result.Error.Message,
+			Retryable: result.Error.Retryable,
+		}
+	}
+
+	return cb
+}
+
+func toCallbackCriterion(c schema.Criterion) CallbackCriterion {
+	return CallbackCriterion{
+		Score:      c.Score,
+		Feedback:   c.Feedback,
+		Deductions: c.Deductions,
+	}
+}
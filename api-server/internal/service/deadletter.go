@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/mq"
+	"github.com/truegul/api-server/internal/repository"
+)
+
+// DeadLetterService lets admins inspect, replay and purge tasks the reaper
+// set aside as undeliverable.
+type DeadLetterService struct {
+	queue        mq.DeadLetterQueue
+	analysisRepo *repository.AnalysisRepository
+}
+
+// NewDeadLetterService accepts a nil queue for backends without a dead-letter
+// queue; every method then reports that it is not supported.
+func NewDeadLetterService(queue mq.DeadLetterQueue, analysisRepo *repository.AnalysisRepository) *DeadLetterService {
+	return &DeadLetterService{
+		queue:        queue,
+		analysisRepo: analysisRepo,
+	}
+}
+
+func (s *DeadLetterService) List(ctx context.Context, before string, limit int) ([]mq.DeadLetter, int64, error) {
+	if s.queue == nil {
+		return nil, 0, errDeadLettersUnsupported()
+	}
+
+	letters, total, err := s.queue.DeadLetters(ctx, before, int64(limit))
+	if err != nil {
+		return nil, 0, apperrors.InternalServerWrap(err, "Failed to list dead letters")
+	}
+	return letters, total, nil
+}
+
+func (s *DeadLetterService) Get(ctx context.Context, id string) (*mq.DeadLetter, error) {
+	if s.queue == nil {
+		return nil, errDeadLettersUnsupported()
+	}
+
+	letter, err := s.queue.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, deadLetterError(err, "Failed to get dead letter")
+	}
+	return letter, nil
+}
+
+// Replay puts the task back on the task stream. Only tasks whose analysis is
+// still in flight are replayed: once the analysis has failed or been
+// cancelled a late result would be ignored, and the writing should be
+// resubmitted instead.
+func (s *DeadLetterService) Replay(ctx context.Context, id string) error {
+	letter, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if letter.TaskID != uuid.Nil {
+		analysis, err := s.analysisRepo.FindByTaskID(letter.TaskID)
+		if err != nil {
+			return err
+		}
+		if analysis.Status.IsTerminal() {
+			return apperrors.InvalidTransition("Analysis is no longer in flight; resubmit the writing instead")
+		}
+	}
+
+	if err := s.queue.ReplayDeadLetter(ctx, id); err != nil {
+		return deadLetterError(err, "Failed to replay dead letter")
+	}
+	return nil
+}
+
+// Purge drops the dead letter with the given ID, or every dead letter when
+// id is empty.
+func (s *DeadLetterService) Purge(ctx context.Context, id string) (int64, error) {
+	if s.queue == nil {
+		return 0, errDeadLettersUnsupported()
+	}
+
+	var ids []string
+	if id != "" {
+		ids = append(ids, id)
+	}
+
+	purged, err := s.queue.PurgeDeadLetters(ctx, ids...)
+	if err != nil {
+		return 0, apperrors.InternalServerWrap(err, "Failed to purge dead letters")
+	}
+	if id != "" && purged == 0 {
+		return 0, apperrors.NotFound("Dead letter not found")
+	}
+	return purged, nil
+}
+
+func errDeadLettersUnsupported() error {
+	return apperrors.NotImplemented("The message queue backend has no dead-letter queue")
+}
+
+func deadLetterError(err error, message string) error {
+	switch {
+	case errors.Is(err, mq.ErrDeadLetterNotFound):
+		return apperrors.NotFound("Dead letter not found")
+	case errors.Is(err, mq.ErrNotReplayable):
+		return apperrors.Validation("Dead letter has no task to replay")
+	}
+	return apperrors.InternalServerWrap(err, message)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/model"
+)
+
+// ScoreDelta compares one numeric score between two analyses. Either side is
+// nil when that analysis has no such score.
+type ScoreDelta struct {
+	From  *float64
+	To    *float64
+	Delta *float64
+}
+
+type CriterionDiff struct {
+	Criterion       model.RubricCriterion
+	Score           ScoreDelta
+	FromFeedback    *string
+	ToFeedback      *string
+	FeedbackChanged bool
+}
+
+type AnalysisDiff struct {
+	From               *model.Analysis
+	To                 *model.Analysis
+	TotalScore         ScoreDelta
+	AIScore            ScoreDelta
+	Criteria           []CriterionDiff
+	FeedbackChanged    bool
+	AddedSuggestions   []string
+	RemovedSuggestions []string
+	AddedDeductions    []model.Deduction
+	RemovedDeductions  []model.Deduction
+}
+
+func (s *AnalysisService) DiffAnalyses(writingID, userID, fromID, toID uuid.UUID) (*AnalysisDiff, error) {
+	if _, err := s.ownedWriting(writingID, userID); err != nil {
+		return nil, err
+	}
+
+	from, err := s.analysisOfWriting(fromID, writingID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.analysisOfWriting(toID, writingID)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffAnalyses(from, to), nil
+}
+
+func diffAnalyses(from, to *model.Analysis) *AnalysisDiff {
+	diff := &AnalysisDiff{
+		From:            from,
+		To:              to,
+		AIScore:         newScoreDelta(from.AIScore, to.AIScore),
+		FeedbackChanged: !equalStrings(from.Feedback, to.Feedback),
+	}
+
+	fromScore, toScore := from.Score, to.Score
+	if fromScore == nil {
+		fromScore = &model.AnalysisScore{}
+	}
+	if toScore == nil {
+		toScore = &model.AnalysisScore{}
+	}
+
+	diff.TotalScore = newScoreDelta(totalScore(from.Score), totalScore(to.Score))
+
+	for _, c := range []struct {
+		name                     model.RubricCriterion
+		fromScore, toScore       int
+		fromFeedback, toFeedback *string
+	}{
+		{model.RubricCriterionContent, fromScore.ContentScore, toScore.ContentScore, fromScore.ContentFeedback, toScore.ContentFeedback},
+		{model.RubricCriterionStructure, fromScore.StructureScore, toScore.StructureScore, fromScore.StructureFeedback, toScore.StructureFeedback},
+		{model.RubricCriterionLanguage, fromScore.LanguageScore, toScore.LanguageScore, fromScore.LanguageFeedback, toScore.LanguageFeedback},
+	} {
+		criterion := CriterionDiff{
+			Criterion:       c.name,
+			FromFeedback:    c.fromFeedback,
+			ToFeedback:      c.toFeedback,
+			FeedbackChanged: !equalStrings(c.fromFeedback, c.toFeedback),
+		}
+		var fromValue, toValue *float64
+		if from.Score != nil {
+			v := float64(c.fromScore)
+			fromValue = &v
+		}
+		if to.Score != nil {
+			v := float64(c.toScore)
+			toValue = &v
+		}
+		criterion.Score = newScoreDelta(fromValue, toValue)
+		diff.Criteria = append(diff.Criteria, criterion)
+	}
+
+	diff.AddedSuggestions, diff.RemovedSuggestions = setDiff(fromScore.Suggestions, toScore.Suggestions)
+	diff.AddedDeductions, diff.RemovedDeductions = setDiff(fromScore.Deductions, toScore.Deductions)
+
+	return diff
+}
+
+func newScoreDelta(from, to *float64) ScoreDelta {
+	delta := ScoreDelta{From: from, To: to}
+	if from != nil && to != nil {
+		d := *to - *from
+		delta.Delta = &d
+	}
+	return delta
+}
+
+func totalScore(score *model.AnalysisScore) *float64 {
+	if score == nil {
+		return nil
+	}
+	total := float64(score.TotalScore)
+	return &total
+}
+
+func equalStrings(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// setDiff returns the items only present in to (added) and only present in
+// from (removed), preserving their original order.
+func setDiff[T comparable](from, to []T) (added, removed []T) {
+	inFrom := make(map[T]bool, len(from))
+	for _, item := range from {
+		inFrom[item] = true
+	}
+	inTo := make(map[T]bool, len(to))
+	for _, item := range to {
+		inTo[item] = true
+	}
+
+	added, removed = []T{}, []T{}
+	for _, item := range to {
+		if !inFrom[item] {
+			added = append(added, item)
+		}
+	}
+	for _, item := range from {
+		if !inTo[item] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+	"github.com/truegul/api-server/internal/repository"
+)
+
+type PlanService struct {
+	planRepo *repository.PlanRepository
+	userRepo *repository.UserRepository
+}
+
+func NewPlanService(planRepo *repository.PlanRepository, userRepo *repository.UserRepository) *PlanService {
+	return &PlanService{
+		planRepo: planRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *PlanService) List() ([]*data.Plan, error) {
+	return s.planRepo.FindAll()
+}
+
+// Assign moves the user onto the plan. Usage counted so far carries over, so
+// a downgrade takes effect against submissions already made today.
+func (s *PlanService) Assign(userID uuid.UUID, planCode string) (*data.Plan, error) {
+	plan, err := s.planRepo.FindByCode(planCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdatePlan(userID, plan.Code); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
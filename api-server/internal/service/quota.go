@@ -0,0 +1,55 @@
+package service
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+)
+
+// Quota reports the user's submission allowance as SubmitWriting enforces
+// it: against their plan's limits, over their own local day and month.
+func (s *AnalysisService) Quota(userID uuid.UUID) (*data.Quota, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.planRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.userRepo.SubmissionUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dayEnd, monthEnd := quotaResets(time.Now(), user.Timezone)
+	return &data.Quota{
+		Plan: plan,
+		Daily: data.QuotaWindow{
+			Limit:   plan.DailySubmissionLimit,
+			Used:    usage.Daily,
+			ResetAt: dayEnd,
+		},
+		Monthly: data.QuotaWindow{
+			Limit:   plan.MonthlySubmissionLimit,
+			Used:    usage.Monthly,
+			ResetAt: monthEnd,
+		},
+	}, nil
+}
+
+// quotaResets returns the next local midnight and the start of the next local
+// month in the given IANA timezone. The database knows a few zone names that
+// Go's tzdata might not; those fall back to UTC.
+func quotaResets(now time.Time, timezone string) (time.Time, time.Time) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	year, month, day := now.In(loc).Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, loc), time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/model"
+)
+
+type CallbackCriterion struct {
+	Score      int
+	Feedback   string
+	Deductions []string
+}
+
+type CallbackRubric struct {
+	Content       CallbackCriterion
+	Structure     CallbackCriterion
+	Language      CallbackCriterion
+	Total         int
+	LevelEstimate string
+	Suggestions   []string
+}
+
+// validateRubric checks the scores themselves; whether a rubric is required
+// at all is up to the result's schema version.
+func validateRubric(rubric *CallbackRubric) error {
+	if rubric == nil {
+		return nil
+	}
+
+	criteria := []struct {
+		name  model.RubricCriterion
+		score int
+		max   int
+	}{
+		{model.RubricCriterionContent, rubric.Content.Score, model.MaxContentScore},
+		{model.RubricCriterionStructure, rubric.Structure.Score, model.MaxStructureScore},
+		{model.RubricCriterionLanguage, rubric.Language.Score, model.MaxLanguageScore},
+	}
+
+	sum := 0
+	for _, c := range criteria {
+		if c.score < 0 || c.score > c.max {
+			return apperrors.Validation(fmt.Sprintf("Rubric %s score must be between 0 and %d", c.name, c.max))
+		}
+		sum += c.score
+	}
+
+	if rubric.Total != sum {
+		return apperrors.Validation(fmt.Sprintf("Rubric total must equal the sum of criterion scores (%d)", sum))
+	}
+	return nil
+}
+
+func toScoreModel(analysisID uuid.UUID, version string, rubric *CallbackRubric) *model.AnalysisScore {
+	score := &model.AnalysisScore{
+		AnalysisID:        analysisID,
+		SchemaVersion:     version,
+		ContentScore:      rubric.Content.Score,
+		StructureScore:    rubric.Structure.Score,
+		LanguageScore:     rubric.Language.Score,
+		TotalScore:        rubric.Total,
+		ContentFeedback:   optionalString(rubric.Content.Feedback),
+		StructureFeedback: optionalString(rubric.Structure.Feedback),
+		LanguageFeedback:  optionalString(rubric.Language.Feedback),
+		Deductions:        model.Deductions{},
+		Suggestions:       model.StringList(rubric.Suggestions),
+		LevelEstimate:     optionalString(rubric.LevelEstimate),
+	}
+
+	for _, c := range []struct {
+		name      model.RubricCriterion
+		criterion CallbackCriterion
+	}{
+		{model.RubricCriterionContent, rubric.Content},
+		{model.RubricCriterionStructure, rubric.Structure},
+		{model.RubricCriterionLanguage, rubric.Language},
+	} {
+		for _, reason := range c.criterion.Deductions {
+			score.Deductions = append(score.Deductions, model.Deduction{Criterion: c.name, Reason: reason})
+		}
+	}
+
+	return score
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
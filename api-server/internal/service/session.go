@@ -0,0 +1,336 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/truegul/api-server/internal/data"
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/model"
+	"github.com/truegul/api-server/internal/repository"
+)
+
+// sessionTouchInterval bounds how often a session's last-seen time is written
+// as requests come in.
+const sessionTouchInterval = time.Minute
+
+// refreshGracePeriod is how long a rotated refresh token is still accepted.
+// Tabs and retried requests that refresh at the same moment all present the
+// same token; within this window they are handed the same successor rather
+// than being taken for a replay.
+const refreshGracePeriod = 30 * time.Second
+
+// errRefreshTokenReused is returned inside the rotation transaction when the
+// presented refresh token was rotated concurrently.
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// ClientInfo describes the device a session was started from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// AuthTokens are the credentials handed to a client for one session.
+type AuthTokens struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token rotates once: presenting it again within
+// refreshGracePeriod returns the same successor, while presenting it later
+// means it was stolen or replayed, and the whole session is revoked.
+func (s *AuthService) Refresh(refreshToken string) (*data.User, *AuthTokens, error) {
+	tokenHash := hashRefreshToken(refreshToken)
+
+	stored, err := s.sessionRepo.FindRefreshToken(tokenHash)
+	if err != nil {
+		if appErr, ok := apperrors.IsAppError(err); ok && appErr.Code == apperrors.CodeNotFound {
+			return nil, nil, apperrors.Unauthorized("Invalid refresh token")
+		}
+		return nil, nil, err
+	}
+
+	session, err := s.sessionRepo.FindByID(stored.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !session.IsActive(time.Now()) {
+		return nil, nil, apperrors.Unauthorized("Session has expired, please log in again")
+	}
+
+	tokens := &AuthTokens{
+		RefreshToken:     s.successorRefreshToken(refreshToken),
+		RefreshExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if stored.RotatedAt == nil {
+		err = s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+			rotated, err := tx.Sessions.RotateRefreshToken(tokenHash)
+			if err != nil {
+				return err
+			}
+			if !rotated {
+				return errRefreshTokenReused
+			}
+
+			if err := tx.Sessions.CreateRefreshToken(session.ID, hashRefreshToken(tokens.RefreshToken)); err != nil {
+				return err
+			}
+			return tx.Sessions.Extend(session.ID, tokens.RefreshExpiresAt)
+		})
+		if errors.Is(err, errRefreshTokenReused) {
+			// A concurrent request rotated it first; it now falls under the
+			// grace period like any other repeat.
+			stored, err = s.sessionRepo.FindRefreshToken(tokenHash)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if stored.RotatedAt != nil {
+		if err := s.checkGracePeriod(session, stored, tokens); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens.AccessExpiresAt = time.Now().Add(s.jwtExpiry)
+	tokens.AccessToken, err = s.GenerateToken(user, session.ID, tokens.AccessExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// Logout revokes the session behind the client's refresh token or, failing
+// that, its access token. It is best effort: the client is logged out either
+// way once its cookies are cleared.
+func (s *AuthService) Logout(refreshToken, accessToken string) {
+	sessionID, ok := s.sessionOf(refreshToken, accessToken)
+	if !ok {
+		return
+	}
+
+	if err := s.sessionRepo.Revoke(sessionID, data.SessionRevokedLogout); err != nil {
+		log.Printf("Failed to revoke session %s on logout: %v", sessionID, err)
+	}
+}
+
+func (s *AuthService) ListSessions(userID uuid.UUID) ([]*data.Session, error) {
+	return s.sessionRepo.FindActiveByUserID(userID)
+}
+
+// RevokeSession logs one of the user's devices out.
+func (s *AuthService) RevokeSession(userID, sessionID uuid.UUID) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	// Other users' sessions are reported as missing rather than forbidden so
+	// that session IDs cannot be probed.
+	if session.UserID != userID {
+		return apperrors.NotFound("Session not found")
+	}
+
+	return s.sessionRepo.Revoke(sessionID, data.SessionRevokedByUser)
+}
+
+// RevokeAllSessions logs the user out on every device, including the one
+// making the request.
+func (s *AuthService) RevokeAllSessions(userID uuid.UUID) (int64, error) {
+	return s.sessionRepo.RevokeAllForUser(userID, data.SessionRevokedEverywhere)
+}
+
+func (s *AuthService) startSession(user *data.User, client ClientInfo) (*AuthTokens, error) {
+	now := time.Now()
+	tokens := &AuthTokens{
+		AccessExpiresAt:  now.Add(s.jwtExpiry),
+		RefreshExpiresAt: now.Add(s.refreshTTL),
+	}
+
+	session := &data.Session{
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		ExpiresAt: tokens.RefreshExpiresAt,
+	}
+
+	err := s.transactor.WithinTransaction(func(tx *repository.Tx) error {
+		if err := tx.Sessions.Create(session); err != nil {
+			return err
+		}
+
+		var err error
+		tokens.RefreshToken, err = newRefreshToken()
+		if err != nil {
+			return err
+		}
+		return tx.Sessions.CreateRefreshToken(session.ID, hashRefreshToken(tokens.RefreshToken))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens.AccessToken, err = s.GenerateToken(user, session.ID, tokens.AccessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// checkSession rejects access tokens whose session has been revoked or has
+// expired, including tokens issued before sessions existed.
+func (s *AuthService) checkSession(claims *JWTClaims) error {
+	sessionID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return apperrors.Unauthorized("Invalid or expired token")
+	}
+
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		if appErr, ok := apperrors.IsAppError(err); ok && appErr.Code == apperrors.CodeNotFound {
+			return apperrors.Unauthorized("Invalid or expired token")
+		}
+		return err
+	}
+
+	if session.UserID != claims.UserID || !session.IsActive(time.Now()) {
+		return apperrors.Unauthorized("Session has been revoked")
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.sessionRepo.Touch(session.ID); err != nil {
+			log.Printf("Failed to touch session %s: %v", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkGracePeriod lets a rotated token through only while it is still
+// within refreshGracePeriod and its successor was issued to the same session,
+// and revokes the session otherwise.
+func (s *AuthService) checkGracePeriod(session *data.Session, stored *model.RefreshToken, tokens *AuthTokens) error {
+	if time.Since(*stored.RotatedAt) > refreshGracePeriod {
+		return s.revokeReusedSession(session)
+	}
+
+	successor, err := s.sessionRepo.FindRefreshToken(hashRefreshToken(tokens.RefreshToken))
+	if err != nil || successor.SessionID != session.ID {
+		return s.revokeReusedSession(session)
+	}
+	tokens.RefreshExpiresAt = session.ExpiresAt
+	return nil
+}
+
+func (s *AuthService) revokeReusedSession(session *data.Session) error {
+	log.Printf("Refresh token reuse detected for session %s of user %s; revoking session", session.ID, session.UserID)
+	if err := s.sessionRepo.Revoke(session.ID, data.SessionRevokedTokenReuse); err != nil {
+		return err
+	}
+	return apperrors.Unauthorized("Refresh token has already been used, please log in again")
+}
+
+// sessionOf finds the session a client's cookies belong to. The access token
+// may have expired, so its claims are read without validating expiry.
+func (s *AuthService) sessionOf(refreshToken, accessToken string) (uuid.UUID, bool) {
+	if refreshToken != "" {
+		if stored, err := s.sessionRepo.FindRefreshToken(hashRefreshToken(refreshToken)); err == nil {
+			return stored.SessionID, true
+		}
+	}
+
+	if accessToken != "" {
+		claims := &JWTClaims{}
+		_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+			return s.jwtSecret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithoutClaimsValidation())
+		if err == nil {
+			if sessionID, err := uuid.Parse(claims.ID); err == nil {
+				return sessionID, true
+			}
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+func newRefreshToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", apperrors.InternalServerWrap(err, "Failed to generate refresh token")
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// successorRefreshToken derives the token that replaces refreshToken when it
+// is rotated. Deriving it rather than drawing it at random lets a repeat within
+// the grace period be answered with the same successor, while only its hash is
+// ever stored.
+func (s *AuthService) successorRefreshToken(refreshToken string) string {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte("refresh-successor:" + refreshToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashRefreshToken is how refresh tokens are stored, so a leaked database
+// does not leak usable tokens.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeviceName gives a short, human-readable name for the device behind a user
+// agent, for listing sessions.
+func DeviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	var platform string
+	switch {
+	case strings.Contains(ua, "iphone"):
+		platform = "iPhone"
+	case strings.Contains(ua, "ipad"):
+		platform = "iPad"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		platform = "Mac"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	default:
+		return "Unknown device"
+	}
+
+	switch {
+	case strings.Contains(ua, "edg/"):
+		return "Edge on " + platform
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		return "Chrome on " + platform
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios/"):
+		return "Firefox on " + platform
+	case strings.Contains(ua, "safari/"):
+		return "Safari on " + platform
+	default:
+		return platform
+	}
+}
@@ -1,25 +1,33 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/google/uuid"
 	"github.com/truegul/api-server/internal/data"
 	apperrors "github.com/truegul/api-server/internal/errors"
 	"github.com/truegul/api-server/internal/repository"
 )
 
-const MaxContentLength = 2000
-
 type WritingService struct {
 	writingRepo *repository.WritingRepository
+	planRepo    *repository.PlanRepository
 }
 
-func NewWritingService(writingRepo *repository.WritingRepository) *WritingService {
-	return &WritingService{writingRepo: writingRepo}
+func NewWritingService(writingRepo *repository.WritingRepository, planRepo *repository.PlanRepository) *WritingService {
+	return &WritingService{
+		writingRepo: writingRepo,
+		planRepo:    planRepo,
+	}
 }
 
 func (s *WritingService) Create(userID uuid.UUID, writingType, title, content string) (*data.Writing, error) {
-	if len([]rune(content)) > MaxContentLength {
-		return nil, apperrors.ContentTooLong("Content exceeds maximum length of 2000 characters")
+	plan, err := s.planRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkContentLength(plan, content); err != nil {
+		return nil, err
 	}
 
 	writing := &data.Writing{
@@ -65,6 +73,14 @@ func (s *WritingService) Update(id, userID uuid.UUID, writingType, title, conten
 		return nil, apperrors.Forbidden("Access denied")
 	}
 
+	current := writing.Status
+
+	// The text under analysis must stay exactly what the ML server scores,
+	// so only the title can change while a writing is submitted.
+	if writing.Status == data.WritingStatusSubmitted && (writingType != nil || content != nil) {
+		return nil, apperrors.WritingLocked("Writing cannot be edited while it is being analyzed")
+	}
+
 	if writingType != nil {
 		writing.Type = data.WritingType(*writingType)
 	}
@@ -72,19 +88,45 @@ func (s *WritingService) Update(id, userID uuid.UUID, writingType, title, conten
 		writing.Title = *title
 	}
 	if content != nil {
-		if len([]rune(*content)) > MaxContentLength {
-			return nil, apperrors.ContentTooLong("Content exceeds maximum length of 2000 characters")
+		plan, err := s.planRepo.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkContentLength(plan, *content); err != nil {
+			return nil, err
 		}
 		writing.Content = *content
 	}
 
-	if err := s.writingRepo.Update(writing); err != nil {
+	// Editing an analyzed writing starts a new revision: the scored content
+	// stays on its revision snapshot and the writing becomes a draft again.
+	if writing.Status == data.WritingStatusAnalyzed && (writingType != nil || content != nil) {
+		if err := transitionWriting(writing, data.WritingStatusDraft); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.writingRepo.Update(writing, current)
+	if err != nil {
 		return nil, err
 	}
+	if !updated {
+		return nil, apperrors.Conflict("Writing was changed by another request; reload it and try again")
+	}
 
 	return writing, nil
 }
 
+func (s *WritingService) ListRevisions(id, userID uuid.UUID) ([]*data.WritingRevision, error) {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return nil, err
+	}
+
+	return s.writingRepo.FindRevisions(id)
+}
+
+// Delete moves the writing to the trash, where it can be restored until the
+// purge job removes it for good.
 func (s *WritingService) Delete(id, userID uuid.UUID) error {
 	writing, err := s.writingRepo.FindByID(id)
 	if err != nil {
@@ -95,5 +137,43 @@ func (s *WritingService) Delete(id, userID uuid.UUID) error {
 		return apperrors.Forbidden("Access denied")
 	}
 
+	// A trashed writing is invisible to the analysis callback, so it must not
+	// disappear while the ML server is still scoring it.
+	if writing.Status == data.WritingStatusSubmitted {
+		return apperrors.WritingLocked("Writing cannot be deleted while it is being analyzed")
+	}
+
 	return s.writingRepo.Delete(id)
 }
+
+func (s *WritingService) ListTrash(userID uuid.UUID, page, limit int) ([]*data.Writing, int64, error) {
+	offset := (page - 1) * limit
+	return s.writingRepo.FindTrashedByUserID(userID, offset, limit)
+}
+
+func (s *WritingService) Restore(id, userID uuid.UUID) (*data.Writing, error) {
+	writing, err := s.writingRepo.FindTrashedByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if writing.UserID != userID {
+		return nil, apperrors.Forbidden("Access denied")
+	}
+
+	if err := s.writingRepo.Restore(id); err != nil {
+		return nil, err
+	}
+
+	writing.DeletedAt = nil
+	return writing, nil
+}
+
+// checkContentLength enforces the plan's maximum content length, counted in
+// characters rather than bytes.
+func checkContentLength(plan *data.Plan, content string) error {
+	if len([]rune(content)) > plan.MaxContentLength {
+		return apperrors.ContentTooLong(fmt.Sprintf("Content exceeds maximum length of %d characters", plan.MaxContentLength))
+	}
+	return nil
+}
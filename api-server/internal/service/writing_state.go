@@ -0,0 +1,25 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/truegul/api-server/internal/data"
+	apperrors "github.com/truegul/api-server/internal/errors"
+)
+
+// transitionWriting moves writing to next if the writing state machine allows
+// it and returns an INVALID_STATUS_TRANSITION error otherwise.
+func transitionWriting(writing *data.Writing, next data.WritingStatus) error {
+	if !writing.Status.CanTransitionTo(next) {
+		return apperrors.InvalidTransition(fmt.Sprintf("Writing cannot move from %s to %s", writing.Status, next))
+	}
+	writing.Status = next
+	return nil
+}
+
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,28 @@
+package worker
+
+import "context"
+
+// Locker runs a function under a lock shared by every replica.
+type Locker interface {
+	TryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error)
+}
+
+// exclusiveJob runs the wrapped job on at most one replica at a time. A
+// replica that finds the lock taken skips the run and tries again on its
+// next tick.
+type exclusiveJob struct {
+	Job
+	locker Locker
+	key    int64
+}
+
+// Exclusive wraps job so that only one replica runs it at a time. Each job
+// needs its own key.
+func Exclusive(job Job, locker Locker, key int64) Job {
+	return &exclusiveJob{Job: job, locker: locker, key: key}
+}
+
+func (j *exclusiveJob) RunOnce(ctx context.Context) error {
+	_, err := j.locker.TryLock(ctx, j.key, j.Job.RunOnce)
+	return err
+}
@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/callbackauth"
+	"github.com/truegul/api-server/internal/mq"
+)
+
+const (
+	forwardTimeout      = 10 * time.Second
+	forwardRetryBackoff = 5 * time.Second
+)
+
+// TaskForwarder is the consumer of the in-process channel backend. The ML
+// worker runs in its own process, so tasks and cancels are handed to it over
+// HTTP, signed like callbacks. A task the ML server does not take is parked
+// for a few seconds and picked up again once the retry scheduler releases it.
+//
+// A cancel the ML server does not take is only logged: the worker then
+// finishes the task, and the cancelled analysis ignores its result.
+type TaskForwarder struct {
+	publisher *mq.ChannelPublisher
+	taskURL   string
+	cancelURL string
+	secret    string
+	client    *http.Client
+}
+
+func NewTaskForwarder(publisher *mq.ChannelPublisher, mlServerURL, secret string) *TaskForwarder {
+	return &TaskForwarder{
+		publisher: publisher,
+		taskURL:   mlServerURL + "/internal/tasks",
+		cancelURL: mlServerURL + "/internal/cancels",
+		secret:    secret,
+		client:    &http.Client{Timeout: forwardTimeout},
+	}
+}
+
+func (f *TaskForwarder) Name() string {
+	return "task-forwarder"
+}
+
+// RunOnce forwards every task waiting in the channel, so the buffer drains
+// between runs and publishers never block on it for long.
+func (f *TaskForwarder) RunOnce(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case cancel := <-f.publisher.Cancels():
+			if err := f.forward(ctx, f.cancelURL, cancel); err != nil {
+				log.Printf("%s: forward cancel for task %s: %v", f.Name(), cancel.TaskID, err)
+			}
+		case task := <-f.publisher.Tasks():
+			if err := f.forward(ctx, f.taskURL, task); err != nil {
+				if parkErr := f.publisher.PublishAt(ctx, task, time.Now().Add(forwardRetryBackoff)); parkErr != nil {
+					return fmt.Errorf("park task %s: %w", task.TaskID, parkErr)
+				}
+				return fmt.Errorf("forward task %s: %w", task.TaskID, err)
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (f *TaskForwarder) forward(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.NewString()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(callbackauth.TimestampHeader, timestamp)
+	req.Header.Set(callbackauth.NonceHeader, nonce)
+	req.Header.Set(callbackauth.SignatureHeader, callbackauth.Sign(f.secret, timestamp, nonce, body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("ML server answered %s", resp.Status)
+	}
+	return nil
+}
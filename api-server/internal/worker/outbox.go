@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/truegul/api-server/internal/model"
+	"github.com/truegul/api-server/internal/mq"
+	"github.com/truegul/api-server/internal/repository"
+	"github.com/truegul/api-server/internal/service"
+)
+
+const outboxBatchSize = 100
+
+var (
+	outboxPublished  = expvar.NewInt("outbox_published_total")
+	outboxFailures   = expvar.NewInt("outbox_publish_failures_total")
+	outboxSetAside   = expvar.NewInt("outbox_failed_total")
+	outboxPending    = expvar.NewInt("outbox_pending")
+	outboxLagSeconds = expvar.NewFloat("outbox_lag_seconds")
+)
+
+// OutboxRelay drains outbox_messages into the message queue. Messages are
+// marked published only after the queue accepted them, so delivery is
+// at-least-once and consumers must tolerate duplicates by task ID.
+//
+// A message that fails to publish is retried with backoff, so a queue outage
+// only delays delivery. A message that can never be published, or that is
+// still failing maxAge after it was written, is set aside so it no longer
+// holds up the messages behind it; the analysis it belongs to is failed and
+// its submission refunded, so its writing is handed back.
+type OutboxRelay struct {
+	outboxRepo      *repository.OutboxRepository
+	publisher       mq.Publisher
+	analysisService *service.AnalysisService
+	maxAge          time.Duration
+}
+
+func NewOutboxRelay(outboxRepo *repository.OutboxRepository, publisher mq.Publisher, analysisService *service.AnalysisService, maxAge time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo:      outboxRepo,
+		publisher:       publisher,
+		analysisService: analysisService,
+		maxAge:          maxAge,
+	}
+}
+
+func (r *OutboxRelay) Name() string {
+	return "outbox-relay"
+}
+
+func (r *OutboxRelay) RunOnce(ctx context.Context) error {
+	defer r.recordLag()
+
+	for {
+		published, failed, err := r.outboxRepo.ProcessBatch(outboxBatchSize, r.maxAge, func(message *model.OutboxMessage) error {
+			if err := r.publish(ctx, message); err != nil {
+				outboxFailures.Add(1)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		outboxPublished.Add(int64(published))
+		for i := range failed {
+			r.abandon(ctx, &failed[i])
+		}
+		if published+len(failed) < outboxBatchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, message *model.OutboxMessage) error {
+	switch message.Kind {
+	case model.OutboxKindAnalysisTask:
+		var task mq.AnalysisTask
+		if err := json.Unmarshal([]byte(message.Payload), &task); err != nil {
+			return fmt.Errorf("%w: decode analysis task: %v", repository.ErrUndeliverable, err)
+		}
+		return r.publisher.Publish(ctx, task)
+	case model.OutboxKindAnalysisRetry:
+		var scheduled mq.ScheduledTask
+		if err := json.Unmarshal([]byte(message.Payload), &scheduled); err != nil {
+			return fmt.Errorf("%w: decode analysis retry: %v", repository.ErrUndeliverable, err)
+		}
+		return r.publisher.PublishAt(ctx, scheduled.Task, scheduled.At)
+	case model.OutboxKindAnalysisCancel:
+		var cancel mq.CancelTask
+		if err := json.Unmarshal([]byte(message.Payload), &cancel); err != nil {
+			return fmt.Errorf("%w: decode analysis cancel: %v", repository.ErrUndeliverable, err)
+		}
+		return r.publisher.PublishCancel(ctx, cancel)
+	default:
+		return fmt.Errorf("%w: unknown outbox message kind %q", repository.ErrUndeliverable, message.Kind)
+	}
+}
+
+// abandon fails the analysis behind a message that was set aside. Cancels
+// need nothing more, and a payload that does not decode names no analysis;
+// the reaper expires those once their SLA runs out.
+func (r *OutboxRelay) abandon(ctx context.Context, message *model.OutboxMessage) {
+	outboxSetAside.Add(1)
+	log.Printf("%s: set aside message %d (%s) after %d attempt(s)", r.Name(), message.ID, message.Kind, message.Attempts+1)
+
+	var task mq.AnalysisTask
+	switch message.Kind {
+	case model.OutboxKindAnalysisTask:
+		if err := json.Unmarshal([]byte(message.Payload), &task); err != nil {
+			return
+		}
+	case model.OutboxKindAnalysisRetry:
+		var scheduled mq.ScheduledTask
+		if err := json.Unmarshal([]byte(message.Payload), &scheduled); err != nil {
+			return
+		}
+		task = scheduled.Task
+	default:
+		return
+	}
+
+	if err := r.analysisService.FailUndeliverable(ctx, task.TaskID); err != nil {
+		log.Printf("%s: fail analysis for task %s: %v", r.Name(), task.TaskID, err)
+	}
+}
+
+func (r *OutboxRelay) recordLag() {
+	pending, oldest, err := r.outboxRepo.Lag()
+	if err != nil {
+		return
+	}
+
+	outboxPending.Set(pending)
+	if oldest == nil {
+		outboxLagSeconds.Set(0)
+		return
+	}
+	outboxLagSeconds.Set(time.Since(*oldest).Seconds())
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/truegul/api-server/internal/repository"
+)
+
+const purgeBatchSize = 100
+
+// TrashPurger permanently deletes writings that have been in the trash for
+// longer than the retention window.
+type TrashPurger struct {
+	writingRepo *repository.WritingRepository
+	retention   time.Duration
+}
+
+func NewTrashPurger(writingRepo *repository.WritingRepository, retention time.Duration) *TrashPurger {
+	return &TrashPurger{writingRepo: writingRepo, retention: retention}
+}
+
+func (p *TrashPurger) Name() string {
+	return "trash-purger"
+}
+
+func (p *TrashPurger) RunOnce(ctx context.Context) error {
+	before := time.Now().Add(-p.retention)
+
+	var total int64
+	for ctx.Err() == nil {
+		purged, err := p.writingRepo.PurgeDeletedBefore(before, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		total += purged
+		if purged < purgeBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("%s: purged %d writing(s) trashed before %s", p.Name(), total, before.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/truegul/api-server/internal/model"
+	"github.com/truegul/api-server/internal/mq"
+	"github.com/truegul/api-server/internal/repository"
+	"github.com/truegul/api-server/internal/service"
+)
+
+const reaperBatchSize = 100
+
+// ReaperLockKey is the advisory lock that keeps replicas from sweeping at
+// the same time and expiring or re-queueing the same analysis twice.
+const ReaperLockKey int64 = 0x7265617065720001
+
+// Reaper finds analyses the ML worker never answered. Tasks that a worker
+// claimed but has been sitting on for longer than the SLA are acknowledged on
+// its behalf and re-queued; analyses that went quiet without any claimed task
+// are failed with a timeout. With a queue backend that cannot be inspected,
+// inspector is nil and every stale analysis is failed.
+//
+// Claimed tasks that cannot be decoded are moved to the dead-letter queue when
+// the backend has one; otherwise they are acknowledged, which drops them. With
+// a dead-letter queue, an abandoned task that has already been delivered
+// maxDeliveries times, counted across its attempts, is dead-lettered too
+// instead of being re-queued once more; its analysis fails with a timeout.
+type Reaper struct {
+	analysisService *service.AnalysisService
+	analysisRepo    *repository.AnalysisRepository
+	inspector       mq.Inspector
+	deadLetters     mq.DeadLetterQueue
+	group           string
+	sla             time.Duration
+	maxDeliveries   int64
+}
+
+func NewReaper(
+	analysisService *service.AnalysisService,
+	analysisRepo *repository.AnalysisRepository,
+	inspector mq.Inspector,
+	deadLetters mq.DeadLetterQueue,
+	group string,
+	sla time.Duration,
+	maxDeliveries int64,
+) *Reaper {
+	return &Reaper{
+		analysisService: analysisService,
+		analysisRepo:    analysisRepo,
+		inspector:       inspector,
+		deadLetters:     deadLetters,
+		group:           group,
+		sla:             sla,
+		maxDeliveries:   maxDeliveries,
+	}
+}
+
+func (r *Reaper) Name() string {
+	return "analysis-reaper"
+}
+
+func (r *Reaper) RunOnce(ctx context.Context) error {
+	var pending []mq.PendingTask
+	if r.inspector != nil {
+		var err error
+		pending, err = r.inspector.PendingTasks(ctx, r.group)
+		if err != nil {
+			return err
+		}
+		pending = r.deadLetter(ctx, pending)
+	}
+
+	claimed := make(map[uuid.UUID]bool, len(pending))
+	claimedIDs := make([]uuid.UUID, 0, len(pending))
+	for _, task := range pending {
+		claimed[task.TaskID] = true
+		claimedIDs = append(claimedIDs, task.TaskID)
+	}
+
+	if err := r.analysisRepo.MarkProcessing(claimedIDs); err != nil {
+		return err
+	}
+
+	for _, task := range pending {
+		if task.Idle < r.sla {
+			continue
+		}
+		if err := r.requeue(ctx, task); err != nil {
+			log.Printf("%s: requeue task %s: %v", r.Name(), task.TaskID, err)
+		}
+	}
+
+	stale, err := r.analysisRepo.FindStale(time.Now().Add(-r.sla), reaperBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range stale {
+		analysis := &stale[i]
+		if analysis.TaskID == nil || claimed[*analysis.TaskID] {
+			continue
+		}
+		if err := r.analysisService.Expire(ctx, analysis, false); err != nil {
+			log.Printf("%s: expire analysis %s: %v", r.Name(), analysis.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// deadLetter moves malformed tasks to the dead-letter queue, or acknowledges
+// them when the backend has none, and returns the rest. Malformed tasks are
+// dropped from the result either way, since there is no analysis to match
+// them to.
+func (r *Reaper) deadLetter(ctx context.Context, pending []mq.PendingTask) []mq.PendingTask {
+	deliverable := pending[:0]
+	for _, task := range pending {
+		if !task.Malformed {
+			deliverable = append(deliverable, task)
+			continue
+		}
+		if r.deadLetters == nil {
+			if err := r.inspector.Ack(ctx, r.group, task.MessageID); err != nil {
+				log.Printf("%s: ack malformed message %s: %v", r.Name(), task.MessageID, err)
+				continue
+			}
+			log.Printf("%s: dropped malformed message %s: no dead-letter queue", r.Name(), task.MessageID)
+			continue
+		}
+
+		if err := r.deadLetters.DeadLetter(ctx, r.group, task, mq.DeadLetterReasonMalformed); err != nil {
+			log.Printf("%s: dead-letter message %s: %v", r.Name(), task.MessageID, err)
+			continue
+		}
+		log.Printf("%s: dead-lettered message %s (%s)", r.Name(), task.MessageID, mq.DeadLetterReasonMalformed)
+	}
+	return deliverable
+}
+
+func (r *Reaper) requeue(ctx context.Context, task mq.PendingTask) error {
+	analysis, err := r.analysisRepo.FindByTaskID(task.TaskID)
+	if err != nil {
+		return err
+	}
+
+	// A worker that is still sending heartbeats is slow, not dead.
+	if time.Since(analysis.UpdatedAt) < r.sla {
+		return nil
+	}
+
+	inFlight := analysis.Status == model.AnalysisStatusPending || analysis.Status == model.AnalysisStatusProcessing
+
+	if r.exhausted(task) {
+		if err := r.deadLetters.DeadLetter(ctx, r.group, task, mq.DeadLetterReasonMaxDeliveries); err != nil {
+			return err
+		}
+		log.Printf("%s: dead-lettered task %s after %d deliveries", r.Name(), task.TaskID, task.TaskDeliveries())
+		if inFlight {
+			return r.analysisService.Expire(ctx, analysis, false)
+		}
+		return nil
+	}
+
+	if inFlight {
+		if err := r.analysisService.Expire(ctx, analysis, true); err != nil {
+			return err
+		}
+	}
+
+	return r.inspector.Ack(ctx, r.group, task.MessageID)
+}
+
+// exhausted reports whether an abandoned task has used up its deliveries and
+// should be dead-lettered rather than re-queued.
+func (r *Reaper) exhausted(task mq.PendingTask) bool {
+	return r.deadLetters != nil && r.maxDeliveries > 0 && task.TaskDeliveries() >= r.maxDeliveries
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/truegul/api-server/internal/migrations"
+	"github.com/truegul/api-server/internal/mq"
+)
+
+// testDB connects to TEST_DATABASE_URL after bringing its schema up to date.
+// Tests that need it are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	source, err := iofs.New(migrations.FS, migrations.Dir)
+	if err != nil {
+		t.Fatalf("open migrations: %v", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return db
+}
+
+func TestReaperAcksMalformedTasksWithoutDeadLetterQueue(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	queue := "test_tasks_" + uuid.NewString()
+	publisher := mq.NewPostgresPublisher(db, queue, queue+"_control")
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM mq_messages WHERE queue = ?", queue)
+	})
+
+	// Valid JSON the worker cannot decode as a task, claimed by a worker.
+	if err := db.Exec("INSERT INTO mq_messages (queue, payload) VALUES (?, ?)", queue, `{"task_id": 42}`).Error; err != nil {
+		t.Fatalf("seed malformed task: %v", err)
+	}
+	if _, err := publisher.Claim(ctx, queue, "test-worker", 10); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	pending, err := publisher.PendingTasks(ctx, "")
+	if err != nil || len(pending) != 1 || !pending[0].Malformed {
+		t.Fatalf("pending = %+v, %v; want one malformed task", pending, err)
+	}
+
+	reaper := NewReaper(nil, nil, publisher, nil, "", time.Minute, 3)
+	if deliverable := reaper.deadLetter(ctx, pending); len(deliverable) != 0 {
+		t.Fatalf("deadLetter kept %+v, want nothing", deliverable)
+	}
+
+	if pending, err := publisher.PendingTasks(ctx, ""); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after reaping = %+v, %v; want none", pending, err)
+	}
+}
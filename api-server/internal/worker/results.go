@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	apperrors "github.com/truegul/api-server/internal/errors"
+	"github.com/truegul/api-server/internal/mq"
+	"github.com/truegul/api-server/internal/service"
+)
+
+const (
+	resultBatchSize = 20
+	resultReadBlock = 2 * time.Second
+)
+
+// ResultConsumer applies ML results read from the results stream, the
+// stream-based alternative to the HTTP callback. A result is acknowledged
+// only once HandleCallback has committed it; results that failed for a
+// transient reason stay pending and are claimed again after the reader's
+// claim idle time. A result that has failed maxDeliveries times is given up
+// on: its analysis fails and the result is acknowledged, so a result that
+// can never be applied is not claimed forever.
+type ResultConsumer struct {
+	analysisService *service.AnalysisService
+	reader          *mq.ResultReader
+	maxDeliveries   int64
+	groupReady      bool
+}
+
+func NewResultConsumer(analysisService *service.AnalysisService, reader *mq.ResultReader, maxDeliveries int64) *ResultConsumer {
+	return &ResultConsumer{
+		analysisService: analysisService,
+		reader:          reader,
+		maxDeliveries:   maxDeliveries,
+	}
+}
+
+func (c *ResultConsumer) Name() string {
+	return "result-consumer"
+}
+
+// RunOnce drains the stream, returning once a read comes back empty.
+func (c *ResultConsumer) RunOnce(ctx context.Context) error {
+	if !c.groupReady {
+		if err := c.reader.EnsureGroup(ctx); err != nil {
+			return err
+		}
+		c.groupReady = true
+	}
+
+	claimed, err := c.reader.Claim(ctx, resultBatchSize)
+	if err != nil {
+		return err
+	}
+	c.handle(ctx, claimed)
+
+	for ctx.Err() == nil {
+		messages, err := c.reader.Read(ctx, resultBatchSize, resultReadBlock)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		c.handle(ctx, messages)
+	}
+	return nil
+}
+
+func (c *ResultConsumer) handle(ctx context.Context, messages []mq.ResultMessage) {
+	for _, message := range messages {
+		if err := c.apply(ctx, message); err != nil {
+			switch {
+			case isPermanent(err):
+				log.Printf("%s: dropping result %s: %v", c.Name(), message.ID, err)
+			case c.exhausted(message):
+				if err := c.giveUp(ctx, message); err != nil {
+					log.Printf("%s: give up on result %s: %v", c.Name(), message.ID, err)
+					continue
+				}
+				log.Printf("%s: gave up on result %s after %d deliveries: %v", c.Name(), message.ID, message.Deliveries, err)
+			default:
+				log.Printf("%s: result %s left pending: %v", c.Name(), message.ID, err)
+				continue
+			}
+		}
+
+		if err := c.reader.Ack(ctx, message.ID); err != nil {
+			log.Printf("%s: ack result %s: %v", c.Name(), message.ID, err)
+		}
+	}
+}
+
+func (c *ResultConsumer) apply(ctx context.Context, message mq.ResultMessage) error {
+	cb, err := service.DecodeCallback(message.Payload)
+	if err != nil {
+		return err
+	}
+	return c.analysisService.HandleCallback(ctx, cb)
+}
+
+// exhausted reports whether a result that failed for a transient reason has
+// used up its deliveries.
+func (c *ResultConsumer) exhausted(message mq.ResultMessage) bool {
+	return c.maxDeliveries > 0 && message.Deliveries >= c.maxDeliveries
+}
+
+// giveUp fails the analysis a result belongs to. The result decoded before
+// it failed, so only the analysis lookup and update can go wrong here; an
+// analysis that is gone or already settled leaves nothing to fail.
+func (c *ResultConsumer) giveUp(ctx context.Context, message mq.ResultMessage) error {
+	cb, err := service.DecodeCallback(message.Payload)
+	if err != nil {
+		return nil
+	}
+	err = c.analysisService.FailUnapplied(ctx, cb.TaskID)
+	if err != nil && isPermanent(err) {
+		return nil
+	}
+	return err
+}
+
+// isPermanent reports whether redelivering a result could never succeed:
+// the result is malformed, names an unknown task or conflicts with the
+// analysis state. The HTTP callback answers these with a 4xx.
+func isPermanent(err error) bool {
+	appErr, ok := apperrors.IsAppError(err)
+	if !ok {
+		return false
+	}
+	switch appErr.Code {
+	case apperrors.CodeValidation, apperrors.CodeNotFound, apperrors.CodeConflict, apperrors.CodeInvalidState:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"log"
+
+	"github.com/truegul/api-server/internal/mq"
+)
+
+// RetryScheduler releases delayed analysis tasks onto the task stream once
+// their backoff has elapsed.
+type RetryScheduler struct {
+	scheduler mq.Scheduler
+}
+
+func NewRetryScheduler(scheduler mq.Scheduler) *RetryScheduler {
+	return &RetryScheduler{scheduler: scheduler}
+}
+
+func (s *RetryScheduler) Name() string {
+	return "retry-scheduler"
+}
+
+func (s *RetryScheduler) RunOnce(ctx context.Context) error {
+	promoted, err := s.scheduler.PromoteDue(ctx)
+	if err != nil {
+		return err
+	}
+	if promoted > 0 {
+		log.Printf("%s: released %d delayed task(s)", s.Name(), promoted)
+	}
+	return nil
+}
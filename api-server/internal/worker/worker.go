@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a unit of background work that is executed periodically.
+type Job interface {
+	Name() string
+	RunOnce(ctx context.Context) error
+}
+
+// Run executes job immediately and then every interval until ctx is done.
+// Errors are logged and do not stop the loop.
+func Run(ctx context.Context, job Job, interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("%s: disabled (interval %s)", job.Name(), interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.RunOnce(ctx); err != nil {
+			log.Printf("%s: %v", job.Name(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE analyses DROP COLUMN progress;
+ALTER TABLE analyses DROP COLUMN stage;
@@ -0,0 +1,2 @@
+-- Distinguish administrators from regular users
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,7 @@
+-- SHA-256 of the exact content sent to the ML server
+ALTER TABLE analyses ADD COLUMN content_hash VARCHAR(64);
+
+UPDATE analyses a
+SET content_hash = encode(sha256(convert_to(r.content, 'UTF8')), 'hex')
+FROM writing_revisions r
+WHERE r.id = a.revision_id AND a.content_hash IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_writings_deleted_at;
+ALTER TABLE writings DROP COLUMN deleted_at;
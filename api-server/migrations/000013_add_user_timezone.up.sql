@@ -0,0 +1,2 @@
+-- IANA zone that defines where the user's submission day starts and ends
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
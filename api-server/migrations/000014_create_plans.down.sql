@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_plan_code;
+ALTER TABLE users DROP COLUMN monthly_submit_count;
+ALTER TABLE users DROP COLUMN plan_code;
+DROP TABLE IF EXISTS plans;
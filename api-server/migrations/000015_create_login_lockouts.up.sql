@@ -0,0 +1,14 @@
+-- Audit trail of accounts and client IPs locked out after repeated failed logins
+CREATE TABLE login_lockouts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('account', 'ip')),
+    email VARCHAR(255),
+    ip_address VARCHAR(45) NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_lockouts_email ON login_lockouts(email);
+CREATE INDEX idx_login_lockouts_ip_address ON login_lockouts(ip_address);
+CREATE INDEX idx_login_lockouts_created_at ON login_lockouts(created_at);
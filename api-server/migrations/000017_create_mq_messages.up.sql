@@ -0,0 +1,15 @@
+-- Queue storage for the postgres message-queue backend. A row is claimed by
+-- setting claimed_at and deleted once the consumer acknowledges it.
+CREATE TABLE mq_messages (
+    id BIGSERIAL PRIMARY KEY,
+    queue VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    claimed_by VARCHAR(100),
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    deliveries INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_mq_messages_available ON mq_messages(queue, available_at) WHERE claimed_at IS NULL;
+CREATE INDEX idx_mq_messages_claimed ON mq_messages(queue, claimed_at) WHERE claimed_at IS NOT NULL;
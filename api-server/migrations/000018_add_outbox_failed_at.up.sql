@@ -0,0 +1,7 @@
+-- Messages that could not be published within the attempt cap, or whose
+-- payload could never be published, are set aside so they stop blocking the
+-- relay.
+ALTER TABLE outbox_messages ADD COLUMN failed_at TIMESTAMP WITH TIME ZONE;
+
+DROP INDEX IF EXISTS idx_outbox_messages_unpublished;
+CREATE INDEX idx_outbox_messages_unpublished ON outbox_messages(id) WHERE published_at IS NULL AND failed_at IS NULL;
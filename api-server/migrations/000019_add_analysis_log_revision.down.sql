@@ -0,0 +1,7 @@
+UPDATE analysis_logs l SET input_text = r.content
+FROM writing_revisions r
+WHERE l.input_text IS NULL AND l.revision_id = r.id;
+UPDATE analysis_logs SET input_text = '' WHERE input_text IS NULL;
+
+ALTER TABLE analysis_logs ALTER COLUMN input_text SET NOT NULL;
+ALTER TABLE analysis_logs DROP COLUMN IF EXISTS revision_id;
@@ -0,0 +1,4 @@
+-- Logs reference the scored revision instead of copying its text into every
+-- row; input_text is only kept for analyses that predate revisions.
+ALTER TABLE analysis_logs ADD COLUMN revision_id UUID REFERENCES writing_revisions(id) ON DELETE SET NULL;
+ALTER TABLE analysis_logs ALTER COLUMN input_text DROP NOT NULL;
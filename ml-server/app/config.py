@@ -64,7 +64,18 @@ class Settings(BaseSettings):
     llm_max_tokens: int = 1024
 
     # MQ Configuration
+    # Must match the api-server's MQ_BACKEND: redis, postgres or channel (the
+    # api-server then POSTs tasks to /internal/tasks)
+    mq_backend: str = "redis"
+    database_url: str = ""
+    mq_poll_interval_ms: int = 1000
+    task_max_skew_seconds: int = 300
     stream_name: str = "analysis_tasks"
+    # Where the api-server publishes cancels; must match its CONTROL_STREAM_NAME
+    control_stream_name: str = "analysis_control"
+    # How long a cancelled task ID is remembered; matches the api-server's
+    # mq.CancelRetention
+    cancel_ttl_seconds: int = 3600
     consumer_group: str = "ml_workers"
     consumer_name: str = "worker_1"
     max_retries: int = 3
@@ -3,14 +3,21 @@ import contextlib
 import logging
 from contextlib import asynccontextmanager
 
-from fastapi import FastAPI, Request
+from fastapi import FastAPI, HTTPException, Request
 from fastapi.middleware.cors import CORSMiddleware
 
 from app.config import settings
 from app.model_adapter import ModelAdapter
 from app.model_loader.creator import create_model_loaders
-from app.services.callback import CallbackClient
-from app.services.consumer import create_task_processor
+from app.mq import QueueConsumer, cancelled_task_id
+from app.services.callback import (
+    NONCE_HEADER,
+    SIGNATURE_HEADER,
+    TIMESTAMP_HEADER,
+    CallbackClient,
+    verify_signature,
+)
+from app.services.consumer import TaskProcessor, create_task_processor
 
 logging.basicConfig(
     level=logging.INFO,
@@ -35,6 +42,7 @@ async def lifespan(app: FastAPI):
 
     task_processor = create_task_processor(app.state.detector, app.state.feedback, callback_client)
     await task_processor.connect()
+    app.state.task_processor = task_processor
 
     consumer_task = asyncio.create_task(task_processor.start())
 
@@ -64,6 +72,46 @@ app.add_middleware(
 )
 
 
+async def _channel_request(request: Request) -> tuple[TaskProcessor, QueueConsumer, bytes]:
+    """Check a signed request from an api-server running MQ_BACKEND=channel."""
+    task_processor = getattr(request.app.state, "task_processor", None)
+    consumer = task_processor.consumer if task_processor else None
+    if not isinstance(consumer, QueueConsumer):
+        raise HTTPException(status_code=404, detail="Not Found")
+
+    body = await request.body()
+    if not verify_signature(
+        settings.ml_callback_secret,
+        request.headers.get(TIMESTAMP_HEADER, ""),
+        request.headers.get(NONCE_HEADER, ""),
+        body,
+        request.headers.get(SIGNATURE_HEADER, ""),
+        settings.task_max_skew_seconds,
+    ):
+        raise HTTPException(status_code=401, detail="Invalid signature")
+    return task_processor, consumer, body
+
+
+@app.post("/internal/tasks", status_code=202)
+async def submit_task(request: Request):
+    """Task intake for an api-server running MQ_BACKEND=channel."""
+    _, consumer, body = await _channel_request(request)
+    if not consumer.submit(body.decode()):
+        raise HTTPException(status_code=503, detail="Task queue is full")
+    return {"status": "queued"}
+
+
+@app.post("/internal/cancels", status_code=202)
+async def cancel_task(request: Request):
+    """Cancel intake for an api-server running MQ_BACKEND=channel."""
+    task_processor, _, body = await _channel_request(request)
+    task_id = cancelled_task_id(body.decode())
+    if task_id is None:
+        raise HTTPException(status_code=400, detail="Invalid cancel")
+    task_processor.cancel(task_id)
+    return {"status": "cancelled"}
+
+
 @app.get("/health")
 async def health_check(request: Request):
     services = {}
@@ -1,4 +1,14 @@
-from app.mq.base import Consumer, Message
+from app.mq.base import DEAD_LETTER_REASON_MALFORMED, Consumer, Message, cancelled_task_id
+from app.mq.http import QueueConsumer
+from app.mq.postgres import PostgresConsumer
 from app.mq.redis import RedisConsumer
 
-__all__ = ["Consumer", "Message", "RedisConsumer"]
+__all__ = [
+    "DEAD_LETTER_REASON_MALFORMED",
+    "Consumer",
+    "Message",
+    "PostgresConsumer",
+    "QueueConsumer",
+    "RedisConsumer",
+    "cancelled_task_id",
+]
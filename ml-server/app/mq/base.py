@@ -1,7 +1,14 @@
+import json
+import logging
 from abc import ABC, abstractmethod
 from collections.abc import Awaitable, Callable
 from dataclasses import dataclass
 
+logger = logging.getLogger(__name__)
+
+# Mirrors the api-server's mq.DeadLetterReasonMalformed.
+DEAD_LETTER_REASON_MALFORMED = "malformed"
+
 
 @dataclass
 class Message:
@@ -10,6 +17,18 @@ class Message:
 
 
 MessageHandler = Callable[[Message], Awaitable[None]]
+CancelHandler = Callable[[str], None]
+
+
+def cancelled_task_id(cancel: str | dict | None) -> str | None:
+    """Extract the task ID from a cancel the api-server published."""
+    if isinstance(cancel, str):
+        try:
+            cancel = json.loads(cancel)
+        except ValueError:
+            return None
+    task_id = cancel.get("task_id") if isinstance(cancel, dict) else None
+    return task_id if isinstance(task_id, str) else None
 
 
 class Consumer(ABC):
@@ -32,3 +51,20 @@ class Consumer(ABC):
     @abstractmethod
     async def ack(self, message_id: str) -> None:
         pass
+
+    async def watch_cancels(self, on_cancel: CancelHandler) -> None:
+        """Call on_cancel with the task ID of every cancel the api-server
+        publishes from now on, until stopped.
+
+        Backends without a control channel return at once; their cancels
+        arrive some other way.
+        """
+        return
+
+    async def dead_letter(self, message: Message, reason: str) -> None:
+        """Set aside a message that cannot be processed.
+
+        Backends without a dead-letter queue log the message and drop it.
+        """
+        logger.error(f"Dropping message {message.id} ({reason}): {message.data}")
+        await self.ack(message.id)
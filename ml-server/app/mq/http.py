@@ -0,0 +1,52 @@
+import asyncio
+import logging
+import uuid
+
+from app.mq.base import Consumer, Message, MessageHandler
+
+logger = logging.getLogger(__name__)
+
+
+class QueueConsumer(Consumer):
+    """Consumes tasks an api-server with MQ_BACKEND=channel POSTs to /internal/tasks.
+
+    Tasks are held in memory only; one lost in a restart is re-queued or
+    expired by the api-server's reaper, as with any unanswered task.
+    """
+
+    def __init__(self, max_pending: int = 1024):
+        self._queue: asyncio.Queue[Message] = asyncio.Queue(maxsize=max_pending)
+        self._running = False
+
+    async def connect(self) -> None:
+        pass
+
+    async def disconnect(self) -> None:
+        pass
+
+    def submit(self, task_json: str) -> bool:
+        """Queue a task; returns False when the queue is full."""
+        try:
+            self._queue.put_nowait(Message(id=uuid.uuid4().hex, data={"task": task_json}))
+            return True
+        except asyncio.QueueFull:
+            return False
+
+    async def start(self, handler: MessageHandler) -> None:
+        self._running = True
+        logger.info("Starting HTTP task consumer...")
+
+        while self._running:
+            try:
+                message = await self._queue.get()
+                await handler(message)
+            except asyncio.CancelledError:
+                break
+            except Exception as e:
+                logger.exception(f"Error in consumer loop: {e}")
+
+    async def stop(self) -> None:
+        self._running = False
+
+    async def ack(self, message_id: str) -> None:
+        pass
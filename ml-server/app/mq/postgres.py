@@ -0,0 +1,118 @@
+import asyncio
+import json
+import logging
+
+import asyncpg
+
+from app.mq.base import CancelHandler, Consumer, Message, MessageHandler, cancelled_task_id
+
+logger = logging.getLogger(__name__)
+
+# Mirrors the api-server's PostgresPublisher.Claim: SKIP LOCKED keeps
+# concurrent workers from claiming the same row, and a claimed row stays
+# invisible until it is acked (deleted) or the api-server's reaper re-queues it.
+CLAIM_SQL = """
+UPDATE mq_messages
+SET claimed_by = $1, claimed_at = NOW(), deliveries = deliveries + 1
+WHERE id IN (
+    SELECT id FROM mq_messages
+    WHERE queue = $2 AND claimed_at IS NULL AND available_at <= NOW()
+    ORDER BY id
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, payload
+"""
+
+ACK_SQL = "DELETE FROM mq_messages WHERE id = $1 AND queue = $2"
+
+# Cancels are read, never claimed, so that every worker sees every one; the
+# api-server deletes them once they are too old to matter.
+LATEST_CANCEL_SQL = "SELECT COALESCE(MAX(id), 0) FROM mq_messages WHERE queue = $1"
+CANCELS_SQL = "SELECT id, payload FROM mq_messages WHERE queue = $1 AND id > $2 ORDER BY id"
+
+
+class PostgresConsumer(Consumer):
+    """Consumes tasks the api-server queued with MQ_BACKEND=postgres."""
+
+    def __init__(
+        self,
+        database_url: str,
+        queue_name: str,
+        control_queue_name: str,
+        consumer_name: str,
+        poll_interval: float = 1.0,
+        batch_size: int = 1,
+    ):
+        self._database_url = database_url
+        self._queue_name = queue_name
+        self._control_queue_name = control_queue_name
+        self._consumer_name = consumer_name
+        self._poll_interval = poll_interval
+        self._batch_size = batch_size
+        self._pool: asyncpg.Pool | None = None
+        self._running = False
+
+    async def connect(self) -> None:
+        self._pool = await asyncpg.create_pool(self._database_url, min_size=1, max_size=2)
+
+    async def disconnect(self) -> None:
+        if self._pool:
+            await self._pool.close()
+
+    async def start(self, handler: MessageHandler) -> None:
+        if self._pool is None:
+            raise RuntimeError("Postgres pool not connected. Call connect() first.")
+
+        self._running = True
+        logger.info("Starting Postgres consumer...")
+        pool = self._pool
+
+        while self._running:
+            try:
+                rows = await pool.fetch(
+                    CLAIM_SQL, self._consumer_name, self._queue_name, self._batch_size
+                )
+                if not rows:
+                    await asyncio.sleep(self._poll_interval)
+                    continue
+
+                for row in rows:
+                    payload = row["payload"]
+                    if not isinstance(payload, str):
+                        payload = json.dumps(payload)
+                    await handler(Message(id=str(row["id"]), data={"task": payload}))
+
+            except asyncio.CancelledError:
+                break
+            except Exception as e:
+                logger.exception(f"Error in consumer loop: {e}")
+                await asyncio.sleep(1)
+
+    async def watch_cancels(self, on_cancel: CancelHandler) -> None:
+        if self._pool is None:
+            raise RuntimeError("Postgres pool not connected. Call connect() first.")
+
+        pool = self._pool
+        last_id = await pool.fetchval(LATEST_CANCEL_SQL, self._control_queue_name)
+        while True:
+            try:
+                for row in await pool.fetch(CANCELS_SQL, self._control_queue_name, last_id):
+                    last_id = row["id"]
+                    task_id = cancelled_task_id(row["payload"])
+                    if task_id:
+                        on_cancel(task_id)
+                await asyncio.sleep(self._poll_interval)
+
+            except asyncio.CancelledError:
+                break
+            except Exception as e:
+                logger.exception(f"Error reading cancels: {e}")
+                await asyncio.sleep(1)
+
+    async def stop(self) -> None:
+        self._running = False
+
+    async def ack(self, message_id: str) -> None:
+        if self._pool:
+            await self._pool.execute(ACK_SQL, int(message_id), self._queue_name)
@@ -1,9 +1,10 @@
 import asyncio
 import logging
+from datetime import UTC, datetime
 
 import redis.asyncio as redis
 
-from app.mq.base import Consumer, Message, MessageHandler
+from app.mq.base import CancelHandler, Consumer, Message, MessageHandler, cancelled_task_id
 
 logger = logging.getLogger(__name__)
 
@@ -15,9 +16,11 @@ class RedisConsumer(Consumer):
         stream_name: str,
         consumer_group: str,
         consumer_name: str,
+        control_stream_name: str,
     ):
         self._redis_url = redis_url
         self._stream_name = stream_name
+        self._control_stream_name = control_stream_name
         self._consumer_group = consumer_group
         self._consumer_name = consumer_name
         self._redis: redis.Redis | None = None
@@ -73,9 +76,54 @@ class RedisConsumer(Consumer):
                 logger.exception(f"Error in consumer loop: {e}")
                 await asyncio.sleep(1)
 
+    async def watch_cancels(self, on_cancel: CancelHandler) -> None:
+        """Read the control stream without a consumer group, so that every
+        worker sees every cancel."""
+        if self._redis is None:
+            raise RuntimeError("Redis client not connected. Call connect() first.")
+
+        client = self._redis
+        last_id = "$"
+        while True:
+            try:
+                entries = await client.xread({self._control_stream_name: last_id}, block=5000)
+                for _stream, messages in entries or []:
+                    for message_id, data in messages:
+                        last_id = message_id
+                        task_id = cancelled_task_id(data.get("cancel"))
+                        if task_id:
+                            on_cancel(task_id)
+
+            except asyncio.CancelledError:
+                break
+            except Exception as e:
+                logger.exception(f"Error reading cancels: {e}")
+                await asyncio.sleep(1)
+
     async def stop(self) -> None:
         self._running = False
 
     async def ack(self, message_id: str) -> None:
         if self._redis:
             await self._redis.xack(self._stream_name, self._consumer_group, message_id)
+
+    async def dead_letter(self, message: Message, reason: str) -> None:
+        """Move the message to the api-server's dead-letter stream, in the same
+        shape as its reaper writes, so operators can inspect it from the admin API.
+        """
+        if self._redis is None:
+            return
+        async with self._redis.pipeline(transaction=True) as pipe:
+            pipe.xadd(
+                f"{self._stream_name}:dead",
+                {
+                    "task": message.data.get("task") or "",
+                    "reason": reason,
+                    "message_id": message.id,
+                    "deliveries": 1,
+                    "dead_at": datetime.now(UTC).strftime("%Y-%m-%dT%H:%M:%SZ"),
+                },
+            )
+            pipe.xack(self._stream_name, self._consumer_group, message.id)
+            pipe.xdel(self._stream_name, message.id)
+            await pipe.execute()
@@ -1,6 +1,8 @@
 from enum import StrEnum
 
-from pydantic import BaseModel
+from pydantic import BaseModel, model_validator
+
+SUPPORTED_SCHEMA_MAJORS = ("1", "2")
 
 
 class WritingType(StrEnum):
@@ -14,15 +16,40 @@ class ErrorCode(StrEnum):
     INVALID_INPUT = "INVALID_INPUT"
     TIMEOUT = "TIMEOUT"
     INTERNAL_ERROR = "INTERNAL_ERROR"
+    UNSUPPORTED_SCHEMA = "UNSUPPORTED_SCHEMA"
+
+
+def schema_major(version: str) -> str:
+    return version.split(".", 1)[0]
+
+
+class TaskInput(BaseModel):
+    type: str = "text"
+    text: str
 
 
 class AnalysisTask(BaseModel):
+    """Accepts schema v1 (plain ``content``) and v2 (typed ``input``) tasks."""
+
     version: str = "1"
     task_id: str
     writing_id: str
-    content: str
+    content: str = ""
+    input: TaskInput | None = None
+    scoring: str | None = None
     writing_type: WritingType
-    callback_url: str
+    callback_url: str | None = None
+    result_stream: str | None = None
+
+    @model_validator(mode="after")
+    def _normalize_input(self) -> "AnalysisTask":
+        if schema_major(self.version) not in SUPPORTED_SCHEMA_MAJORS:
+            raise ValueError(f"unsupported task schema version {self.version!r}")
+        if self.input is not None:
+            if self.input.type != "text":
+                raise ValueError(f"unsupported task input type {self.input.type!r}")
+            self.content = self.input.text
+        return self
 
 
 class AnalysisResult(BaseModel):
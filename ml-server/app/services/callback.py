@@ -1,26 +1,76 @@
+import hashlib
+import hmac
 import logging
+import time
+import uuid
 
 import httpx
+import redis.asyncio as redis
 
 from app.config import settings
 from app.schemas.task import AnalysisCallback
 
 logger = logging.getLogger(__name__)
 
-CALLBACK_HEADER = "X-Callback-Secret"
+TIMESTAMP_HEADER = "X-Callback-Timestamp"
+NONCE_HEADER = "X-Callback-Nonce"
+SIGNATURE_HEADER = "X-Callback-Signature"
+
+
+def sign_callback(secret: str, timestamp: str, nonce: str, body: bytes) -> str:
+    """HMAC-SHA256 over "<timestamp>.<nonce>.<body>", as the api-server verifies it."""
+    message = f"{timestamp}.{nonce}.".encode() + body
+    digest = hmac.new(secret.encode(), message, hashlib.sha256).hexdigest()
+    return f"sha256={digest}"
+
+
+def verify_signature(
+    secret: str, timestamp: str, nonce: str, body: bytes, signature: str, max_skew: int
+) -> bool:
+    """Check a request the api-server signed the way it verifies callbacks."""
+    try:
+        if abs(time.time() - int(timestamp)) > max_skew:
+            return False
+    except ValueError:
+        return False
+    expected = sign_callback(secret, timestamp, nonce, body)
+    return hmac.compare_digest(expected, signature)
 
 
 class CallbackClient:
     def __init__(self):
         self._client = httpx.AsyncClient(timeout=30.0)
+        self._redis = redis.from_url(settings.redis_url, decode_responses=True)
+
+    async def publish_result(self, stream: str, payload: AnalysisCallback) -> bool:
+        """Hand the result back on the api-server's results stream instead of over HTTP.
+
+        No MAXLEN is given: the api-server deletes results once it has handled
+        them, and trimming here could drop results it has not read yet.
+        """
+        try:
+            await self._redis.xadd(stream, {"result": payload.model_dump_json()})
+            logger.info(f"Result published for task {payload.task_id}")
+            return True
+        except redis.RedisError as e:
+            logger.error(f"Result publish failed for task {payload.task_id}: {e}")
+            return False
 
     async def send_callback(self, callback_url: str, payload: AnalysisCallback) -> bool:
-        headers = {CALLBACK_HEADER: settings.ml_callback_secret}
+        body = payload.model_dump_json().encode()
+        timestamp = str(int(time.time()))
+        nonce = uuid.uuid4().hex
+        headers = {
+            "Content-Type": "application/json",
+            TIMESTAMP_HEADER: timestamp,
+            NONCE_HEADER: nonce,
+            SIGNATURE_HEADER: sign_callback(settings.ml_callback_secret, timestamp, nonce, body),
+        }
 
         try:
             response = await self._client.post(
                 callback_url,
-                json=payload.model_dump(),
+                content=body,
                 headers=headers,
             )
             response.raise_for_status()
@@ -32,3 +82,4 @@ class CallbackClient:
 
     async def close(self):
         await self._client.aclose()
+        await self._redis.aclose()
@@ -0,0 +1,24 @@
+import time
+
+
+class CancelledTasks:
+    """Task IDs the api-server has cancelled.
+
+    Each ID is remembered for ttl_seconds, long enough to outlast any delivery
+    of the task that may still be queued or in flight.
+    """
+
+    def __init__(self, ttl_seconds: float) -> None:
+        self._ttl = ttl_seconds
+        self._expires: dict[str, float] = {}
+
+    def add(self, task_id: str) -> None:
+        now = time.monotonic()
+        self._expires = {
+            cancelled: expires for cancelled, expires in self._expires.items() if expires > now
+        }
+        self._expires[task_id] = now + self._ttl
+
+    def __contains__(self, task_id: object) -> bool:
+        expires = self._expires.get(task_id) if isinstance(task_id, str) else None
+        return expires is not None and expires > time.monotonic()
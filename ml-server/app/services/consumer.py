@@ -1,16 +1,31 @@
+import asyncio
+import contextlib
+import json
 import logging
 import time
 
+from pydantic import ValidationError
+
 from app.config import settings
-from app.mq import Consumer, Message, RedisConsumer
+from app.mq import (
+    DEAD_LETTER_REASON_MALFORMED,
+    Consumer,
+    Message,
+    PostgresConsumer,
+    QueueConsumer,
+    RedisConsumer,
+)
 from app.schemas.task import (
+    SUPPORTED_SCHEMA_MAJORS,
     AnalysisCallback,
     AnalysisError,
     AnalysisResult,
     AnalysisTask,
     ErrorCode,
+    schema_major,
 )
 from app.services.callback import CallbackClient
+from app.services.cancellation import CancelledTasks
 from app.services.detector import AIDetectorService
 from app.services.feedback import FeedbackService
 
@@ -29,6 +44,16 @@ class TaskProcessor:
         self._detector = detector
         self._feedback = feedback
         self._callback = callback
+        self._cancelled = CancelledTasks(settings.cancel_ttl_seconds)
+
+    @property
+    def consumer(self) -> Consumer:
+        return self._consumer
+
+    def cancel(self, task_id: str) -> None:
+        """Drop the task if it has not started yet, or at its next stage."""
+        logger.info(f"Task {task_id} cancelled")
+        self._cancelled.add(task_id)
 
     async def connect(self) -> None:
         await self._consumer.connect()
@@ -37,35 +62,59 @@ class TaskProcessor:
         await self._consumer.disconnect()
 
     async def start(self) -> None:
-        await self._consumer.start(self._handle_message)
+        watcher = asyncio.create_task(self._consumer.watch_cancels(self.cancel))
+        try:
+            await self._consumer.start(self._handle_message)
+        finally:
+            watcher.cancel()
+            with contextlib.suppress(asyncio.CancelledError):
+                await watcher
 
     async def stop(self) -> None:
         await self._consumer.stop()
 
     async def _handle_message(self, message: Message) -> None:
-        task_data = message.data.get("task")
-        if not task_data:
-            logger.error(f"Invalid message format: {message.data}")
-            await self._consumer.ack(message.id)
+        task_data = message.data.get("task") or ""
+        try:
+            task = AnalysisTask.model_validate_json(task_data)
+        except ValidationError as e:
+            # A task in a schema this worker does not speak still says where
+            # to report, so its analysis fails right away. Anything else that
+            # cannot be decoded has nowhere to report to and is set aside for
+            # an operator instead of being acked and forgotten.
+            unsupported = _unsupported_schema_task(task_data)
+            if unsupported is None:
+                logger.error(f"Dead-lettering undecodable task {message.id}: {e}")
+                await self._consumer.dead_letter(message, DEAD_LETTER_REASON_MALFORMED)
+                return
+            await self._reject_unsupported_schema(message, unsupported)
             return
 
-        task_id: str = "unknown"
-        callback_url: str | None = None
+        task_id = task.task_id
+        callback_url = task.callback_url
+        result_stream = task.result_stream
         callback: AnalysisCallback
 
+        if await self._drop_if_cancelled(message, task_id):
+            return
+
         try:
-            task = AnalysisTask.model_validate_json(task_data)
-            task_id = task.task_id
-            callback_url = task.callback_url
             content = task.content
             writing_type = task.writing_type
             logger.info(f"Processing task: {task_id}")
 
             start_time = time.time()
 
-            ai_score = self._detector.detect(content)
+            # The stages run off the event loop so that cancels keep arriving
+            # while a stage is busy.
+            ai_score = await asyncio.to_thread(self._detector.detect, content)
 
-            feedback = self._feedback.generate_feedback(content, writing_type, ai_score)
+            if await self._drop_if_cancelled(message, task_id):
+                return
+
+            feedback = await asyncio.to_thread(
+                self._feedback.generate_feedback, content, writing_type, ai_score
+            )
 
             latency_ms = int((time.time() - start_time) * 1000)
 
@@ -101,9 +150,77 @@ class TaskProcessor:
                 ),
             )
 
-        if callback_url:
-            await self._callback.send_callback(callback_url, callback)
+        if await self._drop_if_cancelled(message, task_id):
+            return
+
+        await self._deliver(message, callback, callback_url, result_stream)
+
+    async def _drop_if_cancelled(self, message: Message, task_id: str) -> bool:
+        """Ack a cancelled task without reporting on it; the api-server has
+        already settled its analysis."""
+        if task_id not in self._cancelled:
+            return False
+        logger.info(f"Dropping cancelled task {task_id}")
         await self._consumer.ack(message.id)
+        return True
+
+    async def _reject_unsupported_schema(self, message: Message, task: dict) -> None:
+        version = task.get("version")
+        logger.error(f"Rejecting task {task['task_id']} in unsupported schema version {version!r}")
+        callback = AnalysisCallback(
+            task_id=task["task_id"],
+            status="failed",
+            error=AnalysisError(
+                code=ErrorCode.UNSUPPORTED_SCHEMA,
+                message=f"unsupported task schema version {version!r}",
+                retryable=False,
+            ),
+        )
+        callback_url = task.get("callback_url")
+        result_stream = task.get("result_stream")
+        await self._deliver(
+            message,
+            callback,
+            callback_url if isinstance(callback_url, str) else None,
+            result_stream if isinstance(result_stream, str) else None,
+        )
+
+    async def _deliver(
+        self,
+        message: Message,
+        callback: AnalysisCallback,
+        callback_url: str | None,
+        result_stream: str | None,
+    ) -> None:
+        delivered = True
+        if result_stream:
+            delivered = await self._callback.publish_result(result_stream, callback)
+        elif callback_url:
+            delivered = await self._callback.send_callback(callback_url, callback)
+
+        # An undelivered result leaves the task pending, so the api-server's
+        # reaper re-queues it instead of the result being lost.
+        if delivered:
+            await self._consumer.ack(message.id)
+        else:
+            logger.warning(
+                f"Leaving task {callback.task_id} unacknowledged; its result was not delivered"
+            )
+
+
+def _unsupported_schema_task(task_data: str) -> dict | None:
+    """Return the raw task if it names a task and a schema major this worker
+    does not support, or None if it is malformed in some other way."""
+    try:
+        task = json.loads(task_data)
+    except ValueError:
+        return None
+    if not isinstance(task, dict) or not isinstance(task.get("task_id"), str):
+        return None
+    version = task.get("version", "1")
+    if not isinstance(version, str) or schema_major(version) in SUPPORTED_SCHEMA_MAJORS:
+        return None
+    return task
 
 
 def create_task_processor(
@@ -111,10 +228,28 @@ def create_task_processor(
     feedback: FeedbackService,
     callback: CallbackClient,
 ) -> TaskProcessor:
-    consumer = RedisConsumer(
-        redis_url=settings.redis_url,
-        stream_name=settings.stream_name,
-        consumer_group=settings.consumer_group,
-        consumer_name=settings.consumer_name,
-    )
-    return TaskProcessor(consumer, detector, feedback, callback)
+    return TaskProcessor(create_consumer(), detector, feedback, callback)
+
+
+def create_consumer() -> Consumer:
+    if settings.mq_backend == "postgres":
+        if not settings.database_url:
+            raise ValueError("DATABASE_URL is required when MQ_BACKEND=postgres")
+        return PostgresConsumer(
+            database_url=settings.database_url,
+            queue_name=settings.stream_name,
+            control_queue_name=settings.control_stream_name,
+            consumer_name=settings.consumer_name,
+            poll_interval=settings.mq_poll_interval_ms / 1000,
+        )
+    if settings.mq_backend == "channel":
+        return QueueConsumer()
+    if settings.mq_backend == "redis":
+        return RedisConsumer(
+            redis_url=settings.redis_url,
+            stream_name=settings.stream_name,
+            consumer_group=settings.consumer_group,
+            consumer_name=settings.consumer_name,
+            control_stream_name=settings.control_stream_name,
+        )
+    raise ValueError(f"unsupported MQ_BACKEND {settings.mq_backend!r}")